@@ -247,14 +247,14 @@ func TestReplaceAllFunc(t *testing.T) {
 		}
 		actual := re.ReplaceAllStringFunc(tc.input, tc.replacement)
 		if actual != tc.output {
-			t.Errorf("%q.ReplaceFunc(%q,%q) = %q; want %q",
-				tc.pattern, tc.input, tc.replacement, actual, tc.output)
+			t.Errorf("%q.ReplaceFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
 		}
 		// now try bytes
 		actual = string(re.ReplaceAllFunc([]byte(tc.input), func(s []byte) []byte { return []byte(tc.replacement(string(s))) }))
 		if actual != tc.output {
-			t.Errorf("%q.ReplaceFunc(%q,%q) = %q; want %q",
-				tc.pattern, tc.input, tc.replacement, actual, tc.output)
+			t.Errorf("%q.ReplaceFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
 		}
 	}
 }
@@ -488,10 +488,6 @@ func TestQuoteMeta(t *testing.T) {
 	}
 }
 
-/*
- * LiteralPrefix is not supported by rubex
- *
-//LiteralPrefix
 func TestLiteralPrefix(t *testing.T) {
 	for _, tc := range metaTests {
 		// Literal method needs to scan the pattern.
@@ -505,7 +501,7 @@ func TestLiteralPrefix(t *testing.T) {
 		}
 	}
 }
-*/
+
 type numSubexpCase struct {
 	input    string
 	expected int
@@ -0,0 +1,190 @@
+package rubex
+
+import (
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Backend names the engine that runs a Regexp's searches.
+type Backend int
+
+const (
+	// BackendOniguruma runs every search through the C library, or through
+	// the pure-Go backtracker in builds without cgo.
+	BackendOniguruma Backend = iota
+	// BackendGoRegexp runs searches with Go's regexp package, which avoids
+	// the cost of calling into C.
+	BackendGoRegexp
+)
+
+var backendNames = []string{
+	BackendOniguruma: "Oniguruma",
+	BackendGoRegexp:  "Go regexp",
+}
+
+func (backend Backend) String() string {
+	if backend >= 0 && int(backend) < len(backendNames) {
+		return backendNames[backend]
+	}
+	return "Backend(" + strconv.Itoa(int(backend)) + ")"
+}
+
+var goBackendEnabled int32
+
+// SetGoBackend turns on, or off, running patterns on Go's regexp package.
+// While it is on, patterns compiled with Ruby syntax for UTF-8 text that need
+// nothing Go's regexp lacks, and on which the two engines agree, are searched
+// with Go's regexp. Everything else, and any search with SearchOptions, still
+// runs on Oniguruma. It is off by default.
+//
+// Patterns are always compiled by Oniguruma as well, so errors, NumSubexp and
+// the named groups are the same with either backend.
+func SetGoBackend(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&goBackendEnabled, value)
+}
+
+// Backend reports which engine runs the Regexp's searches.
+func (re *Regexp) Backend() Backend {
+	if re.goRegexp != nil {
+		return BackendGoRegexp
+	}
+	return BackendOniguruma
+}
+
+// routeToGo sets up the Go regexp for a pattern that qualifies for it.
+func (re *Regexp) routeToGo() {
+	if atomic.LoadInt32(&goBackendEnabled) == 0 || re.syntax != ONIG_SYNTAX_RUBY || re.encoding != ONIG_ENCODING_UTF8 {
+		return
+	}
+	c, err := convert(re.pattern, re.Options(), "RE2")
+	if err != nil || !agreesWithRE2(c.parsed.root) {
+		return
+	}
+	std, err := regexp.Compile(c.b.String())
+	if err != nil || std.NumSubexp() != re.NumSubexp() {
+		return
+	}
+	re.goRegexp = std
+	re.goMatchesEmpty = minLength(c.parsed.root) == 0
+	re.goLeftContext = usesLeftContext(c.parsed.root)
+}
+
+// agreesWithRE2 rules out the constructs that ToRE2 accepts but that do not
+// behave exactly alike in both engines.
+func agreesWithRE2(n *node) bool {
+	switch n.op {
+	case opLiteral, opClass:
+		// Oniguruma also folds case across several characters
+		return !n.fold
+	case opWordBoundary, opNoWordBoundary:
+		// RE2's \b is ASCII only
+		return false
+	case opBeginLine:
+		// Ruby's ^ does not match after a newline at the end of the text; it
+		// is only safe in front of something that needs a character
+		return false
+	case opRepeat:
+		// the engines treat empty iterations differently
+		if n.max != 1 && minLength(n.subs[0]) == 0 {
+			return false
+		}
+	case opConcat:
+		for i, sub := range n.subs {
+			if sub.op == opBeginLine {
+				if i+1 == len(n.subs) || minLength(n.subs[i+1]) == 0 {
+					return false
+				}
+				continue
+			}
+			if !agreesWithRE2(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, sub := range n.subs {
+		if !agreesWithRE2(sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// minLength returns the fewest runes a node can match.
+func minLength(n *node) int {
+	switch n.op {
+	case opLiteral:
+		return len(n.runes)
+	case opClass, opAnyChar:
+		return 1
+	case opConcat:
+		total := 0
+		for _, sub := range n.subs {
+			total += minLength(sub)
+		}
+		return total
+	case opAlternate:
+		min := -1
+		for _, sub := range n.subs {
+			if l := minLength(sub); min < 0 || l < min {
+				min = l
+			}
+		}
+		return min
+	case opCapture, opGroup, opAtomic:
+		return minLength(n.subs[0])
+	case opRepeat:
+		return n.min * minLength(n.subs[0])
+	}
+	return 0
+}
+
+// usesLeftContext reports whether a node looks at text before the position
+// where a search starts.
+func usesLeftContext(n *node) bool {
+	switch n.op {
+	case opBeginLine, opBeginText, opWordBoundary, opNoWordBoundary, opLookbehind, opNegLookbehind, opSearchStart:
+		return true
+	}
+	for _, sub := range n.subs {
+		if usesLeftContext(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// goText reports whether b[offset:n] can be searched on the Go backend with
+// the options: the Regexp has one, no options are given, and the text is valid
+// UTF-8, which Go's regexp reads differently from Oniguruma otherwise.
+// Checking the text takes time linear in its length, so findAll does it once
+// for all of its searches.
+func (re *Regexp) goText(b []byte, n int, offset int, options SearchOptions) bool {
+	return re.goRegexp != nil && options == ONIG_OPTION_DEFAULT && utf8.Valid(b[offset:n])
+}
+
+// onGo reports whether a search from offset, in text for which goText holds,
+// can run on the Go backend. Go's regexp only sees the text it is given, so a
+// search from mid-text can only go there if the pattern never looks back.
+func (re *Regexp) onGo(goText bool, offset int) bool {
+	return goText && (offset == 0 || !re.goLeftContext)
+}
+
+// goFind is find on the Go backend.
+func (re *Regexp) goFind(b []byte, n int, offset int) []int {
+	match := re.goRegexp.FindSubmatchIndex(b[offset:n])
+	if offset > 0 {
+		for i := range match {
+			if match[i] >= 0 {
+				match[i] += offset
+			}
+		}
+	}
+	return match
+}
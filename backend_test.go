@@ -0,0 +1,164 @@
+package rubex
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Patterns that run on Go's regexp once it is enabled.
+var goBackendPatterns = []string{
+	`abc`,
+	`a+b*`,
+	`(a|ab)(c|bcd)?`,
+	`\w+`,
+	`\d+`,
+	`\s+`,
+	`[^\w\s]+`,
+	`[[:upper:]][[:lower:]]+`,
+	`\p{Greek}+`,
+	`a.c`,
+	`(?m)a.c`,
+	`^\w+$`,
+	`^a|b$`,
+	`\Aab|c\z`,
+	`(x)?(?<n>y)`,
+	`(?<first>a+)(b)?(?<last>c*)`,
+	`a{2,3}?`,
+	`(?:ab){0,2}c`,
+	`x*`,
+	`a*?`,
+	`|a`,
+	`\Ax*`,
+	`$`,
+	`[a-z&&[^aeiou]]+`,
+}
+
+// Patterns that stay on Oniguruma: Go's regexp lacks the construct, or gives
+// it a different meaning.
+var onigurumaOnlyPatterns = []string{
+	`(a)\1`,
+	`a(?=b)`,
+	`(?<=a)b`,
+	`(?>a+)b`,
+	`a++`,
+	`(?i)abc`,
+	`\bab\b`,
+	`a\B`,
+	`^`,
+	`^$`,
+	`(?m:^)x*`,
+	`(a*)*`,
+	`(?:a|)+b`,
+	`a\Z`,
+	`\Ga`,
+	`(?<n>a)\g<n>`,
+}
+
+var goBackendInputs = []string{
+	"",
+	"a",
+	"ab\n",
+	"a\nb\n",
+	"\n",
+	"abc ABC aBc",
+	"aaabbbccc",
+	"abab abc c xyy",
+	"Hello World\nbye",
+	"été αβγ ١٢٣",
+	"a_b    c",
+	"a\xffb\xfe",
+}
+
+// compileOnBackend compiles pattern with the Go backend switched on or off.
+func compileOnBackend(t *testing.T, pattern string, goBackend bool) *Regexp {
+	SetGoBackend(goBackend)
+	defer SetGoBackend(false)
+	re, err := Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compile(%q): unexpected error: %v", pattern, err)
+	}
+	return re
+}
+
+func TestBackendSelection(t *testing.T) {
+	for _, pattern := range goBackendPatterns {
+		if backend := compileOnBackend(t, pattern, false).Backend(); backend != BackendOniguruma {
+			t.Errorf("%q runs on %v while the Go backend is off", pattern, backend)
+		}
+		if backend := compileOnBackend(t, pattern, true).Backend(); backend != BackendGoRegexp {
+			t.Errorf("%q runs on %v; want %v", pattern, backend, BackendGoRegexp)
+		}
+	}
+	for _, pattern := range onigurumaOnlyPatterns {
+		if backend := compileOnBackend(t, pattern, true).Backend(); backend != BackendOniguruma {
+			t.Errorf("%q runs on %v; want %v", pattern, backend, BackendOniguruma)
+		}
+	}
+	SetGoBackend(true)
+	defer SetGoBackend(false)
+	// builds without cgo do not compile these at all
+	if re, err := CompileWithEncoding(`abc`, ONIG_OPTION_DEFAULT, ONIG_ENCODING_ISO_8859_1); err == nil && re.Backend() != BackendOniguruma {
+		t.Errorf("an ISO-8859-1 pattern runs on %v", re.Backend())
+	}
+	if re, err := CompileWithSyntax(`abc`, ONIG_OPTION_DEFAULT, ONIG_SYNTAX_PERL); err == nil && re.Backend() != BackendOniguruma {
+		t.Errorf("a Perl pattern runs on %v", re.Backend())
+	}
+	if backend := Backend(7).String(); backend != "Backend(7)" {
+		t.Errorf("Backend(7).String() = %q", backend)
+	}
+}
+
+func checkBackendsAgree(t *testing.T, onig, goRe *Regexp, input string) {
+	pattern := onig.String()
+	if actual, expected := goRe.FindAllStringSubmatchIndex(input, -1), onig.FindAllStringSubmatchIndex(input, -1); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%q.FindAllStringSubmatchIndex(%q) = %v on Go; %v on Oniguruma", pattern, input, actual, expected)
+	}
+	if actual, expected := goRe.FindStringSubmatchIndex(input), onig.FindStringSubmatchIndex(input); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%q.FindStringSubmatchIndex(%q) = %v on Go; %v on Oniguruma", pattern, input, actual, expected)
+	}
+	if actual, expected := goRe.MatchString(input), onig.MatchString(input); actual != expected {
+		t.Errorf("%q.MatchString(%q) = %v on Go; %v on Oniguruma", pattern, input, actual, expected)
+	}
+	if actual, expected := goRe.ReplaceAllString(input, "<\\0>"), onig.ReplaceAllString(input, "<\\0>"); actual != expected {
+		t.Errorf("%q.ReplaceAllString(%q) = %q on Go; %q on Oniguruma", pattern, input, actual, expected)
+	}
+	if actual, expected := goRe.FindAllStringIndexWithOptions(input, -1, ONIG_OPTION_FIND_NOT_EMPTY), onig.FindAllStringIndexWithOptions(input, -1, ONIG_OPTION_FIND_NOT_EMPTY); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%q.FindAllStringIndexWithOptions(%q) = %v on Go; %v on Oniguruma", pattern, input, actual, expected)
+	}
+}
+
+func TestBackendsAgree(t *testing.T) {
+	for _, pattern := range goBackendPatterns {
+		onig, goRe := compileOnBackend(t, pattern, false), compileOnBackend(t, pattern, true)
+		for _, input := range goBackendInputs {
+			checkBackendsAgree(t, onig, goRe, input)
+		}
+	}
+}
+
+func TestBackendsAgreeRandom(t *testing.T) {
+	alphabet := []string{"a", "b", "c", "x", "y", "A", "é", " ", "\n", "1"}
+	random := rand.New(rand.NewSource(14))
+	for _, pattern := range goBackendPatterns {
+		onig, goRe := compileOnBackend(t, pattern, false), compileOnBackend(t, pattern, true)
+		for i := 0; i < 100; i++ {
+			input := ""
+			for j := random.Intn(12); j > 0; j-- {
+				input += alphabet[random.Intn(len(alphabet))]
+			}
+			checkBackendsAgree(t, onig, goRe, input)
+		}
+	}
+}
+
+func TestGoBackendFree(t *testing.T) {
+	re := compileOnBackend(t, `a+`, true)
+	re.Free()
+	defer func() {
+		if r := recover(); r != ErrFreed {
+			t.Errorf("searching a freed Regexp panicked with %v; want ErrFreed", r)
+		}
+	}()
+	re.MatchString("aa")
+}
@@ -0,0 +1,830 @@
+package rubex
+
+import (
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A backtracker runs a parsed Ruby-syntax pattern over UTF-8 text the way
+// Oniguruma does: alternatives and repeats are tried in order, and the first
+// match found from the leftmost position wins. It is the engine of builds
+// without cgo.
+//
+// Case-insensitive matching uses simple case folding only, so (?i)ß does not
+// match "ss" as it does in Oniguruma.
+type backtracker struct {
+	root *node
+	// numCaptures counts group 0
+	numCaptures int
+	names       map[string][]int
+	option      Option
+	// sets holds the runes each class node matches, case folds included
+	sets map[*node]runeSet
+	// groups holds the capture nodes by number, for subexpression calls
+	groups []*node
+	calls  map[*node]*node
+	// memoize is false for patterns whose matching depends on more than the
+	// position and what is left to match: backreferences read the groups, and
+	// subexpression calls track the calls that are running
+	memoize bool
+	// steps holds the repeats whose iterations match text in one way only;
+	// see repeatSteps
+	steps map[*node]bool
+}
+
+// maxMatchDepth bounds how deeply repeat iterations and subexpression calls
+// nest, so that a long input fails the search with a *SearchError instead of
+// overflowing the goroutine stack.
+const maxMatchDepth = 20000
+
+func newBacktracker(pattern string, option Option) (*backtracker, error) {
+	parsed, err := parsePattern(pattern, option)
+	if err != nil {
+		return nil, err
+	}
+	bt := &backtracker{
+		root:        parsed.root,
+		numCaptures: parsed.numCaptures + 1,
+		names:       parsed.names,
+		option:      option,
+		sets:        make(map[*node]runeSet),
+		groups:      make([]*node, parsed.numCaptures+1),
+		calls:       make(map[*node]*node),
+		memoize:     true,
+		steps:       make(map[*node]bool),
+	}
+	bt.groups[0] = parsed.root
+	bt.collectGroups(parsed.root)
+	if err := bt.prepare(parsed.root); err != nil {
+		return nil, err
+	}
+	return bt, nil
+}
+
+func (bt *backtracker) prepare(n *node) error {
+	switch n.op {
+	case opClass:
+		if n.fold {
+			bt.sets[n] = n.class.foldedRunes()
+		} else {
+			bt.sets[n] = n.class.runes()
+		}
+	case opCall:
+		target, err := bt.callTarget(n)
+		if err != nil {
+			return err
+		}
+		bt.calls[n] = target
+		bt.memoize = false
+	case opBackref:
+		bt.memoize = false
+	case opRepeat:
+		if isStep(n.subs[0]) && minLength(n.subs[0]) > 0 {
+			bt.steps[n] = true
+		}
+	case opUnsupported:
+		return &unsupportedError{n.name}
+	}
+	for _, sub := range n.subs {
+		if err := bt.prepare(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsupportedError reports a construct only Oniguruma runs.
+type unsupportedError struct {
+	construct string
+}
+
+func (e *unsupportedError) Error() string {
+	return e.construct + " is not supported without cgo"
+}
+
+func (bt *backtracker) collectGroups(n *node) {
+	if n.op == opCapture {
+		bt.groups[n.index] = n
+	}
+	for _, sub := range n.subs {
+		bt.collectGroups(sub)
+	}
+}
+
+// callTarget resolves the group a \g<...> call runs.
+func (bt *backtracker) callTarget(n *node) (*node, error) {
+	if n.name != "" {
+		groups := bt.names[n.name]
+		switch {
+		case groups == nil:
+			return nil, &parseError{n.pos, "undefined name <" + n.name + "> reference"}
+		case len(groups) > 1:
+			return nil, &parseError{n.pos, "multiplex defined name <" + n.name + "> call"}
+		}
+		return bt.groups[groups[0]], nil
+	}
+	if len(bt.names) > 0 && bt.option&ONIG_OPTION_CAPTURE_GROUP == 0 {
+		return nil, &parseError{n.pos, "numbered backref/call is not allowed. (use name)"}
+	}
+	ref := n.refs[0]
+	if ref < 0 {
+		// relative calls count the groups opened before them
+		ref = n.index + ref + 1
+	}
+	if ref < 0 || ref >= len(bt.groups) {
+		return nil, &parseError{n.pos, "undefined group <" + strconv.Itoa(n.refs[0]) + "> reference"}
+	}
+	return bt.groups[ref], nil
+}
+
+func (bt *backtracker) newSearch(b []byte, n int, offset int, option Option) *btSearch {
+	return &btSearch{
+		backtracker: bt,
+		b:           b[:n],
+		start:       offset,
+		caps:        make([]int, 2*bt.numCaptures),
+		notBOL:      option&ONIG_OPTION_NOTBOL != 0,
+		notEOL:      option&ONIG_OPTION_NOTEOL != 0,
+	}
+}
+
+// search returns the capture positions of the first match in b[:n] that
+// starts at or after offset, or nil.
+//
+// A state that failed from one start position fails from the next as well,
+// so once a search turns to the memo it keeps it; only
+// ONIG_OPTION_FIND_NOT_EMPTY, which makes the top continuation depend on the
+// start, drops it.
+func (bt *backtracker) search(b []byte, n int, offset int, options SearchOptions) []int {
+	option := bt.option | Option(options)
+	s := bt.newSearch(b, n, offset, option)
+	notEmpty := option&ONIG_OPTION_FIND_NOT_EMPTY != 0
+	longest := option&ONIG_OPTION_FIND_LONGEST != 0
+	var best []int
+	pos := offset
+	accept := func(end int) bool {
+		if notEmpty && end == pos {
+			return false
+		}
+		if longest {
+			// keep the longest match of the whole text, and go on looking
+			if best == nil || end-pos > best[1]-best[0] {
+				best = append(best[:0], s.caps...)
+				best[0], best[1] = pos, end
+			}
+			return false
+		}
+		s.caps[0], s.caps[1] = pos, end
+		return true
+	}
+	scan := func() bool {
+		for {
+			s.tries = 0
+			for i := range s.caps {
+				s.caps[i] = -1
+			}
+			if s.match(bt.root, pos, contTop, accept) {
+				return true
+			}
+			if pos == n {
+				return false
+			}
+			_, width := s.runeAt(pos)
+			pos += width
+			if notEmpty {
+				s.memo = nil
+			}
+		}
+	}
+	if s.run(scan) {
+		return s.caps
+	}
+	return best
+}
+
+// longestAt returns the capture positions of the longest of all the matches
+// in b[:n] that start at pos, or nil. Of matches equally long, the one found
+// first wins. offset is where the search started, for \G.
+//
+// Every way of matching is tried, but a node that is reached again at the
+// same position with the same continuation is skipped when it failed before:
+// it can only find the ends it found the first time, and of those a later
+// one is never preferred. That makes the search polynomial, save for patterns
+// with backreferences or subexpression calls.
+func (bt *backtracker) longestAt(b []byte, n int, offset int, pos int, options SearchOptions) []int {
+	option := bt.option | Option(options)
+	s := bt.newSearch(b, n, offset, option)
+	if bt.memoize {
+		s.memo = newBtMemo()
+	}
+	notEmpty := option&ONIG_OPTION_FIND_NOT_EMPTY != 0
+	for i := range s.caps {
+		s.caps[i] = -1
+	}
+	var best []int
+	s.match(bt.root, pos, contTop, func(end int) bool {
+		if notEmpty && end == pos {
+			return false
+		}
+		if best == nil || end-pos > best[1]-best[0] {
+			best = append(best[:0], s.caps...)
+			best[0], best[1] = pos, end
+		}
+		// fail, to try every other way of matching
+		return false
+	})
+	return best
+}
+
+// memoAfter is how many choices a search makes from one start position
+// before it starts over with the memo. Most searches never get there, and for
+// them the memo costs more than it saves. The tests lower it.
+var memoAfter = 10000
+
+// retryLimit is how many choices a search makes from one start position
+// before it gives up, when the pattern has backreferences or subexpression
+// calls and cannot use the memo.
+const retryLimit = 1000000
+
+// restartWithMemo is the panic value that makes run start over with the memo.
+type restartWithMemo struct{}
+
+// run runs scan, which tries one start position after the other. When one of
+// them makes memoAfter choices without the memo, scan is run again with
+// the memo, to go on from that position.
+func (s *btSearch) run(scan func() bool) bool {
+	for {
+		if matched, finished := s.runUntilRestart(scan); finished {
+			return matched
+		}
+		s.memo = newBtMemo()
+		s.depth = 0
+	}
+}
+
+func (s *btSearch) runUntilRestart(scan func() bool) (matched bool, finished bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, restart := r.(restartWithMemo); !restart {
+				panic(r)
+			}
+		}
+	}()
+	return scan(), true
+}
+
+// btSearch is the state of one search.
+type btSearch struct {
+	*backtracker
+	b              []byte
+	start          int
+	caps           []int
+	notBOL, notEOL bool
+	// calls that are running and have not consumed anything yet; entering
+	// one of them again at the same position would never end
+	active map[btCall]bool
+	// memo is set for patterns that allow it, in leftmost-longest searches
+	// and in searches that backtrack a lot; see run
+	memo *btMemo
+	// depth counts the repeat iterations and calls that are running, and
+	// tries the choices made without the memo
+	depth, tries int
+}
+
+type btCall struct {
+	call *node
+	pos  int
+}
+
+// btMemo records the states a search has tried and found to fail. A state is a node, the position it is matched at, and its
+// continuation: what is left to match after it, which is numbered by
+// interning a btCont for each step the continuation takes.
+type btMemo struct {
+	conts  map[btCont]int
+	failed map[btState]bool
+}
+
+func newBtMemo() *btMemo {
+	return &btMemo{conts: make(map[btCont]int), failed: make(map[btState]bool)}
+}
+
+// the numbers of the continuations that are not interned
+const (
+	// contTop is the continuation a search starts with
+	contTop = iota
+	// contAccept accepts any end, as in atomic groups and lookaheads
+	contAccept
+	numFixedConts
+)
+
+// the kinds of btCont
+const (
+	// the subs of a concatenation from index on
+	contConcat = iota
+	// the group is closed
+	contCapture
+	// a repeat whose count of iterations is index goes on; pos is where the
+	// iteration started, when that matters
+	contRepeat
+	// only the end pos is accepted, as in lookbehinds
+	contEndAt
+)
+
+type btCont struct {
+	kind   int
+	node   *node
+	index  int
+	pos    int
+	parent int
+}
+
+type btState struct {
+	node *node
+	pos  int
+	cont int
+}
+
+// cont returns the number of a continuation, or contTop when the search does
+// not memoize.
+func (s *btSearch) cont(kind int, n *node, index int, pos int, parent int) int {
+	if s.memo == nil {
+		return contTop
+	}
+	key := btCont{kind, n, index, pos, parent}
+	id, ok := s.memo.conts[key]
+	if !ok {
+		id = numFixedConts + len(s.memo.conts)
+		s.memo.conts[key] = id
+	}
+	return id
+}
+
+// failedBefore reports whether a state is known to fail.
+func (s *btSearch) failedBefore(n *node, pos int, cont int) bool {
+	return s.memo != nil && s.memo.failed[btState{n, pos, cont}]
+}
+
+// fail records that a state failed, and returns false.
+func (s *btSearch) fail(n *node, pos int, cont int) bool {
+	if s.memo != nil {
+		s.memo.failed[btState{n, pos, cont}] = true
+	}
+	return false
+}
+
+func (s *btSearch) runeAt(pos int) (rune, int) {
+	if pos >= len(s.b) {
+		return 0, 0
+	}
+	if c := s.b[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(s.b[pos:])
+}
+
+func (s *btSearch) isWordAt(pos int) bool {
+	r, width := s.runeAt(pos)
+	return width > 0 && isWordRune(r)
+}
+
+func (s *btSearch) isWordBefore(pos int) bool {
+	if pos == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRune(s.b[:pos])
+	return isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	}
+	return propWord.runes().contains(r)
+}
+
+func (s *btSearch) snapshot() []int {
+	return append([]int(nil), s.caps...)
+}
+
+func (s *btSearch) restore(saved []int) {
+	copy(s.caps, saved)
+}
+
+// match matches n at pos and calls k with the position after it, trying the
+// ways n can match in order until k returns true. cont numbers k for the
+// memo.
+func (s *btSearch) match(n *node, pos int, cont int, k func(int) bool) bool {
+	switch n.op {
+	case opEmpty:
+		return k(pos)
+	case opLiteral, opClass, opAnyChar:
+		if end := s.step(n, pos); end >= 0 {
+			return k(end)
+		}
+		return false
+	case opBeginLine:
+		// Ruby's ^ does not match after a newline that ends the text
+		if pos == 0 && !s.notBOL || pos > 0 && pos < len(s.b) && s.b[pos-1] == '\n' {
+			return k(pos)
+		}
+		return false
+	case opEndLine:
+		if pos == len(s.b) && !s.notEOL || pos < len(s.b) && s.b[pos] == '\n' {
+			return k(pos)
+		}
+		return false
+	case opBeginText:
+		if pos == 0 && !s.notBOL {
+			return k(pos)
+		}
+		return false
+	case opEndText:
+		if pos == len(s.b) && !s.notEOL {
+			return k(pos)
+		}
+		return false
+	case opEndTextNewline:
+		if (pos == len(s.b) || pos == len(s.b)-1 && s.b[pos] == '\n') && !s.notEOL {
+			return k(pos)
+		}
+		return false
+	case opWordBoundary, opNoWordBoundary:
+		if (s.isWordBefore(pos) != s.isWordAt(pos)) == (n.op == opWordBoundary) {
+			return k(pos)
+		}
+		return false
+	case opSearchStart:
+		if pos == s.start {
+			return k(pos)
+		}
+		return false
+	case opConcat:
+		return s.matchConcat(n, 0, pos, cont, k)
+	case opAlternate:
+		s.try()
+		if s.failedBefore(n, pos, cont) {
+			return false
+		}
+		for _, sub := range n.subs {
+			if s.match(sub, pos, cont, k) {
+				return true
+			}
+		}
+		return s.fail(n, pos, cont)
+	case opGroup:
+		return s.match(n.subs[0], pos, cont, k)
+	case opCapture:
+		i := 2 * n.index
+		return s.match(n.subs[0], pos, s.cont(contCapture, n, 0, 0, cont), func(end int) bool {
+			oldStart, oldEnd := s.caps[i], s.caps[i+1]
+			s.caps[i], s.caps[i+1] = pos, end
+			if k(end) {
+				return true
+			}
+			s.caps[i], s.caps[i+1] = oldStart, oldEnd
+			return false
+		})
+	case opRepeat:
+		if n.possessive {
+			return s.atomic(k, func(k func(int) bool) bool {
+				return s.repeat(n, 0, pos, contAccept, k)
+			})
+		}
+		return s.repeat(n, 0, pos, cont, k)
+	case opAtomic:
+		return s.atomic(k, func(k func(int) bool) bool {
+			return s.match(n.subs[0], pos, contAccept, k)
+		})
+	case opLookahead, opNegLookahead:
+		saved := s.snapshot()
+		found := s.match(n.subs[0], pos, contAccept, func(int) bool { return true })
+		return s.assert(n.op == opLookahead, found, saved, pos, k)
+	case opLookbehind, opNegLookbehind:
+		saved := s.snapshot()
+		found := false
+		limit := maxLength(n.subs[0])
+		endAt := s.cont(contEndAt, nil, 0, pos, contTop)
+		for start, steps := pos, 0; !found && (limit < 0 || steps <= limit); steps++ {
+			found = s.match(n.subs[0], start, endAt, func(end int) bool { return end == pos })
+			if start == 0 {
+				break
+			}
+			_, width := utf8.DecodeLastRune(s.b[:start])
+			start -= width
+		}
+		return s.assert(n.op == opLookbehind, found, saved, pos, k)
+	case opCall:
+		key := btCall{n, pos}
+		if s.active[key] {
+			return false
+		}
+		if s.active == nil {
+			s.active = make(map[btCall]bool)
+		}
+		s.active[key] = true
+		s.enter()
+		matched := s.match(s.calls[n], pos, cont, func(end int) bool {
+			delete(s.active, key)
+			defer func() { s.active[key] = true }()
+			return k(end)
+		})
+		s.leave()
+		delete(s.active, key)
+		return matched
+	case opBackref:
+		// with several groups of the name, the last one that is set and
+		// matches here is used
+		for i := len(n.refs) - 1; i >= 0; i-- {
+			g := n.refs[i]
+			if s.caps[2*g] < 0 {
+				continue
+			}
+			if end, ok := s.matchText(s.b[s.caps[2*g]:s.caps[2*g+1]], pos, n.fold); ok {
+				return k(end)
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// enter is called as a repeat goes on to its next iteration or a
+// subexpression call starts, which is where the match calls nest deeper.
+func (s *btSearch) enter() {
+	if s.depth++; s.depth > maxMatchDepth {
+		panic(&SearchError{Code: ONIGERR_MATCH_STACK_LIMIT_OVER, Message: "match-stack limit over"})
+	}
+	s.try()
+}
+
+func (s *btSearch) leave() {
+	s.depth--
+}
+
+// try counts a choice between ways of matching. After memoAfter of them it
+// starts the search over with the memo, see run, or for a pattern that cannot
+// have one it gives up after retryLimit, as Oniguruma does.
+func (s *btSearch) try() {
+	if s.memo != nil {
+		return
+	}
+	s.tries++
+	if s.memoize && s.tries > memoAfter {
+		panic(restartWithMemo{})
+	}
+	if s.tries > retryLimit {
+		panic(&SearchError{Code: ONIGERR_RETRY_LIMIT_IN_MATCH_OVER, Message: "retry-limit-in-match over"})
+	}
+}
+
+// matchConcat matches the subs of n from index i on.
+func (s *btSearch) matchConcat(n *node, i int, pos int, cont int, k func(int) bool) bool {
+	if i == len(n.subs) {
+		return k(pos)
+	}
+	return s.match(n.subs[i], pos, s.cont(contConcat, n, i+1, 0, cont), func(end int) bool {
+		return s.matchConcat(n, i+1, end, cont, k)
+	})
+}
+
+// atomic runs try, keeps its first match and never backtracks into it.
+func (s *btSearch) atomic(k func(int) bool, try func(func(int) bool) bool) bool {
+	saved := s.snapshot()
+	end := -1
+	if !try(func(e int) bool {
+		end = e
+		return true
+	}) {
+		return false
+	}
+	if k(end) {
+		return true
+	}
+	s.restore(saved)
+	return false
+}
+
+// assert finishes a lookaround: found reports whether its body matched.
+// Groups set in a positive lookaround keep their values.
+func (s *btSearch) assert(positive bool, found bool, saved []int, pos int, k func(int) bool) bool {
+	if !positive {
+		s.restore(saved)
+		found = !found
+	}
+	if found && k(pos) {
+		return true
+	}
+	s.restore(saved)
+	return false
+}
+
+func (s *btSearch) repeat(n *node, count int, pos int, cont int, k func(int) bool) bool {
+	// past the minimum, the count of an unbounded repeat no longer matters
+	state := count
+	if n.max < 0 && state > n.min {
+		state = n.min
+	}
+	repeatCont := s.cont(contRepeat, n, state, -1, cont)
+	if s.failedBefore(n, pos, repeatCont) {
+		return false
+	}
+	s.enter()
+	matched := s.repeatFrom(n, count, state, pos, cont, k) || s.fail(n, pos, repeatCont)
+	s.leave()
+	return matched
+}
+
+func (s *btSearch) repeatFrom(n *node, count int, state int, pos int, cont int, k func(int) bool) bool {
+	sub := n.subs[0]
+	if s.steps[n] {
+		return s.repeatSteps(n, pos, k)
+	}
+	if n.max >= 0 && count >= n.max {
+		return k(pos)
+	}
+	// an iteration that matches nothing ends the loop, so where it started is
+	// part of its continuation when it can
+	start := -1
+	if s.memo != nil && minLength(sub) == 0 {
+		start = pos
+	}
+	more := func() bool {
+		return s.match(sub, pos, s.cont(contRepeat, n, state, start, cont), func(end int) bool {
+			if end == pos {
+				return k(end)
+			}
+			return s.repeat(n, count+1, end, cont, k)
+		})
+	}
+	if count < n.min {
+		return more()
+	}
+	if n.lazy {
+		return k(pos) || more()
+	}
+	return more() || k(pos)
+}
+
+// repeatSteps repeats a node that matches in one way only and never matches
+// nothing, without recursing once per iteration.
+func (s *btSearch) repeatSteps(n *node, pos int, k func(int) bool) bool {
+	sub := n.subs[0]
+	if n.lazy {
+		for count := 0; ; count++ {
+			if count >= n.min && k(pos) {
+				return true
+			}
+			if n.max >= 0 && count == n.max {
+				return false
+			}
+			if pos = s.step(sub, pos); pos < 0 {
+				return false
+			}
+		}
+	}
+	ends := []int{pos}
+	for n.max < 0 || len(ends) <= n.max {
+		if pos = s.step(sub, pos); pos < 0 {
+			break
+		}
+		ends = append(ends, pos)
+	}
+	for i := len(ends) - 1; i >= n.min; i-- {
+		if k(ends[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStep reports whether a node matches in one way only: it is a run of
+// characters with no choice between them.
+func isStep(n *node) bool {
+	switch n.op {
+	case opLiteral, opClass, opAnyChar:
+		return true
+	case opConcat, opGroup:
+		for _, sub := range n.subs {
+			if !isStep(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// step returns the position after the match of a node isStep accepts at pos,
+// or -1.
+func (s *btSearch) step(n *node, pos int) int {
+	switch n.op {
+	case opLiteral:
+		for _, r := range n.runes {
+			c, width := s.runeAt(pos)
+			if width == 0 || c != r && !(n.fold && foldEqual(c, r)) {
+				return -1
+			}
+			pos += width
+		}
+	case opClass, opAnyChar:
+		width := s.single(n, pos)
+		if width == 0 {
+			return -1
+		}
+		pos += width
+	case opConcat, opGroup:
+		for _, sub := range n.subs {
+			if pos = s.step(sub, pos); pos < 0 {
+				return -1
+			}
+		}
+	}
+	return pos
+}
+
+// single returns the width of the character a one-character node matches at
+// pos, or 0.
+func (s *btSearch) single(n *node, pos int) int {
+	c, width := s.runeAt(pos)
+	if width == 0 {
+		return 0
+	}
+	switch n.op {
+	case opLiteral:
+		if c == n.runes[0] || n.fold && foldEqual(c, n.runes[0]) {
+			return width
+		}
+	case opClass:
+		if s.sets[n].contains(c) {
+			return width
+		}
+	case opAnyChar:
+		if c != '\n' || n.dotall {
+			return width
+		}
+	}
+	return 0
+}
+
+// matchText matches the text of a backreference at pos.
+func (s *btSearch) matchText(text []byte, pos int, fold bool) (int, bool) {
+	for len(text) > 0 {
+		r, width := utf8.DecodeRune(text)
+		c, cWidth := s.runeAt(pos)
+		if cWidth == 0 || c != r && !(fold && foldEqual(c, r)) {
+			return 0, false
+		}
+		text = text[width:]
+		pos += cWidth
+	}
+	return pos, true
+}
+
+func foldEqual(a, b rune) bool {
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return a == b
+}
+
+// maxLength returns the most runes a node can match, or -1 if there is no
+// limit.
+func maxLength(n *node) int {
+	switch n.op {
+	case opLiteral:
+		return len(n.runes)
+	case opClass, opAnyChar:
+		return 1
+	case opConcat, opAlternate:
+		max := 0
+		for _, sub := range n.subs {
+			l := maxLength(sub)
+			switch {
+			case l < 0:
+				return -1
+			case n.op == opConcat:
+				max += l
+			case l > max:
+				max = l
+			}
+		}
+		return max
+	case opCapture, opGroup, opAtomic:
+		return maxLength(n.subs[0])
+	case opRepeat:
+		l := maxLength(n.subs[0])
+		if l == 0 {
+			return 0
+		}
+		if l < 0 || n.max < 0 {
+			return -1
+		}
+		return n.max * l
+	case opBackref:
+		return -1
+	}
+	return 0
+}
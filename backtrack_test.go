@@ -0,0 +1,242 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Patterns on which the pure-Go engine must agree with Oniguruma.
+var backtrackPatterns = []string{
+	`a+b*`,
+	`(a|ab)(c|bcd)?(d*)`,
+	`^\w+$`,
+	`(?m)a.b`,
+	`\Aa|b\z|c\Z`,
+	`\bab\b|\Bb\B`,
+	`(?<first>a+)(b)?(?<last>c*)`,
+	`(a)(?:x(b))?\2?`,
+	`(a+)\1`,
+	`(?<x>[ab])\k<x>+`,
+	`(?i)(ab)\1`,
+	`(?<x>a)|(?<x>b)\k<x>`,
+	`a(?=b)`,
+	`a(?!b)\w`,
+	`(?<=a)b+`,
+	`(?<!a|bc)c`,
+	`(?<=\Ab)a`,
+	`(?>a+)b`,
+	`(?>a|ab)c`,
+	`a++b`,
+	`a*+a`,
+	`(a?)+?b`,
+	`(a*)*b`,
+	`(?:a|)+b`,
+	`(a|)*`,
+	`a{2,3}?`,
+	`a{2}?b`,
+	`(?:ab){1,}+`,
+	`[a-c&&[^b]]+`,
+	`(?i)[^a]+`,
+	`(?i)[a-c]+`,
+	`(?i:A)b`,
+	`\p{Greek}+|\P{L}`,
+	`[[:upper:]][[:lower:]]*`,
+	`\d+\s*\h+`,
+	`.\n?`,
+	`(?m:.+)`,
+	`(?x) a b # comment`,
+	`(?<p>\((?:[^()]|\g<p>)*\))`,
+	`\g<1>(x)`,
+	`x|`,
+	`\Ga`,
+}
+
+var backtrackInputs = []string{
+	"",
+	"a",
+	"ab\n",
+	"a\nb\n",
+	"\n",
+	"abc ABC aBc",
+	"aaabbbccc",
+	"abab abc c xyy",
+	"((a)(b(c)))x)",
+	"été αβγ ١٢٣ ΑΒΓ",
+	"dead BEEF  \t12",
+	"baab bcc",
+}
+
+func checkBacktracker(t *testing.T, re *Regexp, bt *backtracker, input string) {
+	b := []byte(input)
+	for offset := 0; offset <= len(b); offset++ {
+		if offset < len(b) && !utf8.RuneStart(b[offset]) {
+			continue
+		}
+		expected := re.find(b, len(b), offset, ONIG_OPTION_DEFAULT)
+		if actual := bt.search(b, len(b), offset, ONIG_OPTION_DEFAULT); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q on %q from %d: backtracker found %v; Oniguruma %v", re.String(), input, offset, actual, expected)
+		}
+	}
+}
+
+func TestBacktracker(t *testing.T) {
+	for _, pattern := range backtrackPatterns {
+		re := MustCompile(pattern)
+		bt, err := newBacktracker(pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			t.Errorf("newBacktracker(%q): unexpected error: %v", pattern, err)
+			continue
+		}
+		for _, input := range backtrackInputs {
+			checkBacktracker(t, re, bt, input)
+		}
+	}
+}
+
+func TestBacktrackerRandom(t *testing.T) {
+	alphabet := []string{"a", "b", "c", "x", "A", "é", " ", "\n", "(", ")"}
+	random := rand.New(rand.NewSource(16))
+	for _, pattern := range backtrackPatterns {
+		re := MustCompile(pattern)
+		bt, err := newBacktracker(pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			continue
+		}
+		for i := 0; i < 50; i++ {
+			input := ""
+			for j := random.Intn(10); j > 0; j-- {
+				input += alphabet[random.Intn(len(alphabet))]
+			}
+			checkBacktracker(t, re, bt, input)
+		}
+	}
+}
+
+func TestBacktrackerOptions(t *testing.T) {
+	options := []Option{ONIG_OPTION_IGNORECASE, ONIG_OPTION_MULTILINE, ONIG_OPTION_SINGLELINE, ONIG_OPTION_FIND_NOT_EMPTY, ONIG_OPTION_CAPTURE_GROUP}
+	for _, option := range options {
+		for _, pattern := range []string{`^a.$`, `(?<n>a*)(b*)`, `\w*`, `[A-Z]+`} {
+			re := MustCompileWithOption(pattern, option)
+			bt, err := newBacktracker(pattern, option)
+			if err != nil {
+				t.Errorf("newBacktracker(%q, %v): unexpected error: %v", pattern, option, err)
+				continue
+			}
+			for _, input := range backtrackInputs {
+				checkBacktracker(t, re, bt, input)
+			}
+		}
+	}
+	for _, options := range []SearchOptions{ONIG_OPTION_NOTBOL, ONIG_OPTION_NOTEOL, ONIG_OPTION_FIND_NOT_EMPTY} {
+		for _, pattern := range []string{`^a|b$`, `(?m)^$`, `a*`} {
+			re := MustCompile(pattern)
+			bt, _ := newBacktracker(pattern, ONIG_OPTION_NONE)
+			for _, input := range backtrackInputs {
+				b := []byte(input)
+				expected := re.find(b, len(b), 0, options)
+				if actual := bt.search(b, len(b), 0, options); !reflect.DeepEqual(actual, expected) {
+					t.Errorf("%q on %q with %d: backtracker found %v; Oniguruma %v", pattern, input, options, actual, expected)
+				}
+			}
+		}
+	}
+}
+
+// TestBacktrackerMemo checks that searches that turn to the memo find the
+// same matches and groups as Oniguruma.
+func TestBacktrackerMemo(t *testing.T) {
+	defer func(saved int) { memoAfter = saved }(memoAfter)
+	memoAfter = 0
+	for _, pattern := range backtrackPatterns {
+		re := MustCompile(pattern)
+		bt, err := newBacktracker(pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			continue
+		}
+		for _, input := range backtrackInputs {
+			checkBacktracker(t, re, bt, input)
+		}
+	}
+	for _, pattern := range []string{`(a|ab)(c|bcd)?(d*)`, `a*`, `(?m)^$`} {
+		re := MustCompile(pattern)
+		bt, _ := newBacktracker(pattern, ONIG_OPTION_NONE)
+		for _, input := range backtrackInputs {
+			b := []byte(input)
+			expected := re.find(b, len(b), 0, ONIG_OPTION_FIND_NOT_EMPTY)
+			if actual := bt.search(b, len(b), 0, ONIG_OPTION_FIND_NOT_EMPTY); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%q on %q not empty: backtracker found %v; Oniguruma %v", pattern, input, actual, expected)
+			}
+		}
+	}
+}
+
+// Patterns that fail on their input in exponentially many ways, which a
+// search must not all try.
+var backtrackPathological = []struct {
+	pattern, input string
+}{
+	{`(a*)*b`, strings.Repeat("a", 100)},
+	{`(a|aa)*b`, strings.Repeat("a", 100)},
+	{`((a|b)+|c)*d`, strings.Repeat("ab", 100)},
+	{`(\w*)*(\w*)*x`, strings.Repeat("a", 50) + " "},
+}
+
+func TestBacktrackerPathological(t *testing.T) {
+	for _, test := range backtrackPathological {
+		re := MustCompile(test.pattern)
+		bt, err := newBacktracker(test.pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			t.Fatalf("newBacktracker(%q): %v", test.pattern, err)
+		}
+		done := make(chan bool, 1)
+		go func() {
+			checkBacktracker(t, re, bt, test.input)
+			done <- true
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q did not finish", test.pattern)
+		}
+	}
+}
+
+// TestBacktrackerLimits checks that long inputs and patterns that cannot be
+// memoized end the search with a *SearchError instead of overflowing the
+// stack or running on.
+func TestBacktrackerLimits(t *testing.T) {
+	input := []byte(strings.Repeat("ab", 1000000))
+	bt, _ := newBacktracker(`(?:ab)*c?`, ONIG_OPTION_NONE)
+	if actual := bt.search(input, len(input), 0, ONIG_OPTION_DEFAULT); !reflect.DeepEqual(actual, []int{0, len(input)}) {
+		t.Errorf("(?:ab)*c? matched %v", actual)
+	}
+	tests := []struct {
+		pattern string
+		input   []byte
+		code    int
+	}{
+		{`(?:ab|cd)*e?`, input, ONIGERR_MATCH_STACK_LIMIT_OVER},
+		{`(?<x>a\g<x>|b)`, []byte(strings.Repeat("a", 100000)), ONIGERR_MATCH_STACK_LIMIT_OVER},
+		{`(a|a)*\1b`, []byte(strings.Repeat("a", 30)), ONIGERR_RETRY_LIMIT_IN_MATCH_OVER},
+	}
+	for _, test := range tests {
+		bt, err := newBacktracker(test.pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			t.Fatalf("newBacktracker(%q): %v", test.pattern, err)
+		}
+		func() {
+			defer func() {
+				if err, ok := recover().(*SearchError); !ok || err.Code != test.code {
+					t.Errorf("%q panicked with %v; want error %d", test.pattern, err, test.code)
+				}
+			}()
+			bt.search(test.input, len(test.input), 0, ONIG_OPTION_DEFAULT)
+		}()
+	}
+}
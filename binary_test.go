@@ -0,0 +1,124 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func TestBinaryAnyByte(t *testing.T) {
+	re := MustCompileBinary(".")
+	for seed := int64(0); seed < 10; seed++ {
+		b := randomBytes(seed, 512)
+		matches := re.FindAllIndex(b, -1)
+		if len(matches) != len(b) {
+			t.Fatalf("seed %d: %d matches; want %d", seed, len(matches), len(b))
+		}
+		for i, m := range matches {
+			if m[0] != i || m[1] != i+1 {
+				t.Fatalf("seed %d: match %d = %v", seed, i, m)
+			}
+		}
+	}
+}
+
+func TestBinaryEmptyMatches(t *testing.T) {
+	re := MustCompileBinary("")
+	b := randomBytes(1, 256)
+	matches := re.FindAllIndex(b, -1)
+	if len(matches) != len(b)+1 {
+		t.Fatalf("%d empty matches; want %d", len(matches), len(b)+1)
+	}
+	for i, m := range matches {
+		if m[0] != i || m[1] != i {
+			t.Fatalf("match %d = %v", i, m)
+		}
+	}
+	if actual := re.ReplaceAll([]byte("\xc3\xa9\xff"), []byte("-")); !bytes.Equal(actual, []byte("-\xc3-\xa9-\xff-")) {
+		t.Errorf("ReplaceAll = %q", actual)
+	}
+}
+
+func TestBinaryByteEscapes(t *testing.T) {
+	b := randomBytes(2, 4096)
+	re := MustCompileBinary(`\xfe\xff`)
+	var expected [][]int
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] == 0xfe && b[i+1] == 0xff {
+			expected = append(expected, []int{i, i + 2})
+		}
+	}
+	if actual := re.FindAllIndex(b, -1); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FindAllIndex = %v; want %v", actual, expected)
+	}
+
+	re = MustCompileBinary(`[\x80-\xff]+`)
+	expected = nil
+	for i := 0; i < len(b); {
+		if b[i] < 0x80 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(b) && b[j] >= 0x80 {
+			j++
+		}
+		expected = append(expected, []int{i, j})
+		i = j
+	}
+	if actual := re.FindAllIndex(b, -1); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FindAllIndex = %v; want %v", actual, expected)
+	}
+}
+
+func TestBinaryHeader(t *testing.T) {
+	re := MustCompileBinary(`\A\x89PNG\r\n\x1a\n(.{4})IHDR`)
+	header := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x01\x00")
+	m := re.FindSubmatch(header)
+	if m == nil || !bytes.Equal(m[1], []byte("\x00\x00\x00\x0d")) {
+		t.Errorf("FindSubmatch = %q", m)
+	}
+	// \w only matches ASCII word characters
+	if actual := MustCompileBinary(`\w+`).FindAll([]byte("ab\xe9cd\xc3\xa9"), -1); len(actual) != 2 {
+		t.Errorf(`\w+ matched %q`, actual)
+	}
+	if re.Encoding() != ONIG_ENCODING_BINARY || re.Encoding().String() != "ASCII-8BIT" {
+		t.Errorf("Encoding() = %s", re.Encoding())
+	}
+	if re.Options() != ONIG_OPTION_NONE {
+		t.Errorf("Options() = %s", re.Options())
+	}
+}
+
+func TestBinaryIsNotASCII(t *testing.T) {
+	if ONIG_ENCODING_BINARY == ONIG_ENCODING_ASCII {
+		t.Fatal("ONIG_ENCODING_BINARY is ONIG_ENCODING_ASCII")
+	}
+	re := MustCompileWithEncoding("\\xff.", ONIG_OPTION_NONE, ONIG_ENCODING_ASCII)
+	if re.Encoding() != ONIG_ENCODING_ASCII || re.Encoding().String() != "ASCII" {
+		t.Errorf("Encoding() = %s", re.Encoding())
+	}
+	if actual := re.FindIndex([]byte("a\xff\n\xffb")); !reflect.DeepEqual(actual, []int{3, 5}) {
+		t.Errorf("FindIndex = %v", actual)
+	}
+	if actual := MustCompileBinary("\\xff.").FindIndex([]byte("a\xff\n")); !reflect.DeepEqual(actual, []int{1, 3}) {
+		t.Errorf("binary FindIndex = %v", actual)
+	}
+	text, _ := MustCompileBinary(".").MarshalText()
+	if string(text) != "/./mn" {
+		t.Errorf("MarshalText() = %q", text)
+	}
+	text, _ = re.MarshalText()
+	if string(text) != `/\xff./ encoding=ASCII` {
+		t.Errorf("ASCII MarshalText() = %q", text)
+	}
+}
@@ -18,8 +18,8 @@ import "sync"
 
 var mu sync.Mutex
 var count = 0
-var re1 []Matcher
-var re2 []Matcher
+var re1 []re.Matcher
+var re2 []re.Matcher
 
 const NUM = 100
 const NNN = 1000
@@ -27,21 +27,17 @@ const CCC = 100000
 
 var STR = "abcdabc"
 
-type Matcher interface {
-	MatchString(string) bool
-}
-
 type Task struct {
 	str string
-	m   Matcher
+	m   re.Matcher
 	t   time.Time
 }
 
 var TaskChann chan *Task
 
 func init() {
-	re1 = make([]Matcher, NUM)
-	re2 = make([]Matcher, NUM)
+	re1 = make([]re.Matcher, NUM)
+	re2 = make([]re.Matcher, NUM)
 	for i := 0; i < NUM; i++ {
 		re1[i] = regexp.MustCompile("[a-c]*$")
 		re2[i] = re.MustCompile("[a-c]*$")
@@ -53,7 +49,7 @@ func init() {
 	fmt.Println("len:", len(STR))
 }
 
-func render_pages(name string, marray []Matcher, num_routines, num_renders int) {
+func render_pages(name string, marray []re.Matcher, num_routines, num_renders int) {
 	for i := 0; i < num_routines; i++ {
 		m := marray[i]
 		go func() {
@@ -78,7 +74,7 @@ func render_pages(name string, marray []Matcher, num_routines, num_renders int)
 	}
 }
 
-func render_pages2(name string, marray []Matcher, num_routines, num_renders int) {
+func render_pages2(name string, marray []re.Matcher, num_routines, num_renders int) {
 	go func() {
 		for i := 0; i < CCC; i++ {
 			t := &Task{str: STR, m: marray[0], t: time.Now()}
@@ -0,0 +1,198 @@
+package rubex
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheCapacity is the number of compiled patterns kept by the
+// package-level helpers (MatchString, FindString, Gsub and their
+// WithEncoding variants) unless changed with SetCacheCapacity.
+const DefaultCacheCapacity = 256
+
+// CacheStats describes the state of the compiled-pattern cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+	Capacity  int
+}
+
+type cacheKey struct {
+	pattern  string
+	option   Option
+	syntax   Syntax
+	encoding Encoding
+}
+
+type cacheEntry struct {
+	key cacheKey
+	re  *Regexp
+}
+
+// regexpCache is a least-recently-used cache of compiled patterns. Entries
+// handed out by get hold a reference on the Regexp, so evicting (and freeing)
+// an entry never disturbs a search that is still running on it.
+type regexpCache struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[cacheKey]*list.Element
+	lru       *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+var cache = newRegexpCache(DefaultCacheCapacity)
+
+func newRegexpCache(capacity int) *regexpCache {
+	return &regexpCache{
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get returns the compiled pattern for key and a function that must be called
+// once the caller is done searching with it.
+func (c *regexpCache) get(key cacheKey) (*Regexp, func(), error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		re := elem.Value.(*cacheEntry).re
+		re.acquire()
+		c.mu.Unlock()
+		return re, re.release, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	//compile outside the lock so that misses on different patterns do not wait on each other
+	re, err := NewRegexpWithEncoding(key.pattern, key.option, key.syntax, key.encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		//another goroutine compiled the same pattern first
+		re.Free()
+		re = elem.Value.(*cacheEntry).re
+		c.lru.MoveToFront(elem)
+		re.acquire()
+		return re, re.release, nil
+	}
+	re.acquire()
+	if c.capacity <= 0 {
+		return re, func() {
+			re.release()
+			re.Free()
+		}, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, re: re})
+	c.evict()
+	return re, re.release, nil
+}
+
+// evict drops least recently used entries until the cache fits its capacity.
+// c.mu must be held.
+func (c *regexpCache) evict() {
+	for c.lru.Len() > c.capacity {
+		elem := c.lru.Back()
+		entry := elem.Value.(*cacheEntry)
+		c.lru.Remove(elem)
+		delete(c.entries, entry.key)
+		entry.re.Free()
+		c.evictions++
+	}
+}
+
+func (c *regexpCache) setCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if capacity < 0 {
+		capacity = 0
+	}
+	c.capacity = capacity
+	c.evict()
+}
+
+func (c *regexpCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.lru.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
+// SetCacheCapacity sets how many compiled patterns the package-level helpers
+// keep. Shrinking the cache frees the evicted patterns; a capacity of 0
+// disables caching.
+func SetCacheCapacity(capacity int) {
+	cache.setCapacity(capacity)
+}
+
+// CacheStatistics returns the hit, miss and eviction counts of the
+// compiled-pattern cache along with its current size and capacity.
+func CacheStatistics() CacheStats {
+	return cache.stats()
+}
+
+// MatchString reports whether the string s contains any match of pattern.
+// The compiled pattern is cached.
+func MatchString(pattern string, s string) (matched bool, error error) {
+	return MatchStringWithEncoding(pattern, ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, s)
+}
+
+// MatchStringWithEncoding is MatchString for a pattern compiled as by
+// NewRegexpWithEncoding. Each combination of pattern, option, syntax and
+// encoding is cached on its own.
+func MatchStringWithEncoding(pattern string, option Option, syntax Syntax, encoding Encoding, s string) (matched bool, error error) {
+	re, done, err := cache.get(cacheKey{pattern, option, syntax, encoding})
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	return re.MatchString(s), nil
+}
+
+// FindString returns the leftmost match of pattern in s, or "" if there is
+// none. The compiled pattern is cached.
+func FindString(pattern string, s string) (string, error) {
+	return FindStringWithEncoding(pattern, ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, s)
+}
+
+// FindStringWithEncoding is FindString for a pattern compiled as by
+// NewRegexpWithEncoding.
+func FindStringWithEncoding(pattern string, option Option, syntax Syntax, encoding Encoding, s string) (string, error) {
+	re, done, err := cache.get(cacheKey{pattern, option, syntax, encoding})
+	if err != nil {
+		return "", err
+	}
+	defer done()
+	return re.FindString(s), nil
+}
+
+// Gsub replaces every match of pattern in src with repl, which may refer to
+// captures as "\\1" or "\\k<name>". The compiled pattern is cached.
+func Gsub(pattern string, src, repl string) (string, error) {
+	return GsubWithEncoding(pattern, ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, src, repl)
+}
+
+// GsubWithEncoding is Gsub for a pattern compiled as by
+// NewRegexpWithEncoding.
+func GsubWithEncoding(pattern string, option Option, syntax Syntax, encoding Encoding, src, repl string) (string, error) {
+	re, done, err := cache.get(cacheKey{pattern, option, syntax, encoding})
+	if err != nil {
+		return "", err
+	}
+	defer done()
+	return re.Gsub(src, repl), nil
+}
@@ -0,0 +1,139 @@
+package rubex
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCacheHitsAndMisses(t *testing.T) {
+	c := newRegexpCache(2)
+	key := cacheKey{"a+", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT}
+	for i := 0; i < 3; i++ {
+		re, done, err := c.get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.MatchString("baaa") {
+			t.Errorf("expected a match")
+		}
+		done()
+	}
+	stats := c.stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 || stats.Capacity != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, _, err := c.get(cacheKey{"(", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT}); err == nil {
+		t.Errorf("expected a compile error")
+	}
+	if stats = c.stats(); stats.Size != 1 {
+		t.Errorf("a pattern that failed to compile was cached: %+v", stats)
+	}
+}
+
+func TestCacheKeyIncludesOptions(t *testing.T) {
+	c := newRegexpCache(2)
+	re1, done1, _ := c.get(cacheKey{"a", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	re2, done2, _ := c.get(cacheKey{"a", ONIG_OPTION_IGNORECASE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	defer done1()
+	defer done2()
+	if re1 == re2 {
+		t.Errorf("patterns compiled with different options share a cache entry")
+	}
+	if re1.MatchString("A") || !re2.MatchString("A") {
+		t.Errorf("cached patterns do not honor their options")
+	}
+}
+
+func TestCacheEvictionFreesLeastRecentlyUsed(t *testing.T) {
+	c := newRegexpCache(2)
+	a, done, _ := c.get(cacheKey{"a", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	done()
+	b, done, _ := c.get(cacheKey{"b", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	done()
+	_, done, _ = c.get(cacheKey{"a", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	done()
+	_, done, _ = c.get(cacheKey{"c", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	done()
+	if b.Close() != ErrFreed {
+		t.Errorf("least recently used pattern was not freed on eviction")
+	}
+	if !a.MatchString("a") {
+		t.Errorf("recently used pattern was evicted")
+	}
+	if stats := c.stats(); stats.Evictions != 1 || stats.Size != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	c.setCapacity(0)
+	if stats := c.stats(); stats.Evictions != 3 || stats.Size != 0 {
+		t.Errorf("unexpected stats after shrinking %+v", stats)
+	}
+}
+
+func TestCacheEvictionDuringSearch(t *testing.T) {
+	c := newRegexpCache(1)
+	re, done, _ := c.get(cacheKey{"x+", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	_, done2, _ := c.get(cacheKey{"y+", ONIG_OPTION_DEFAULT, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT})
+	done2()
+	if s := re.FindString("axxxb"); s != "xxx" {
+		t.Errorf("search on an evicted pattern still in use returned %q", s)
+	}
+	done()
+	if re.Close() != ErrFreed {
+		t.Errorf("evicted pattern was not freed once its last user finished")
+	}
+}
+
+func TestPackageHelpersConcurrently(t *testing.T) {
+	defer SetCacheCapacity(DefaultCacheCapacity)
+	SetCacheCapacity(4)
+	var wg sync.WaitGroup
+	for i := 0; i < numConcurrentRuns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pattern := fmt.Sprintf("(?<n>%d)", i%10)
+			input := fmt.Sprintf("<%d>", i%10)
+			for j := 0; j < numConcurrentSearches; j++ {
+				if ok, err := MatchString(pattern, input); !ok || err != nil {
+					t.Errorf("MatchString(%q, %q) = %t, %v", pattern, input, ok, err)
+				}
+				if s, err := FindString(pattern, input); s != fmt.Sprint(i%10) || err != nil {
+					t.Errorf("FindString(%q, %q) = %q, %v", pattern, input, s, err)
+				}
+				if s, err := Gsub(pattern, input, "[\\k<n>]"); s != fmt.Sprintf("<[%d]>", i%10) || err != nil {
+					t.Errorf("Gsub(%q, %q) = %q, %v", pattern, input, s, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stats := CacheStatistics(); stats.Size > 4 || stats.Evictions == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPackageHelpersWithEncoding(t *testing.T) {
+	if matched, err := MatchStringWithEncoding(`a`, ONIG_OPTION_IGNORECASE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, "A"); err != nil || !matched {
+		t.Errorf("MatchStringWithEncoding = %v, %v; want a case-insensitive match", matched, err)
+	}
+	if matched, _ := MatchString(`a`, "A"); matched {
+		t.Errorf("MatchString used the pattern cached with ONIG_OPTION_IGNORECASE")
+	}
+	if actual, err := FindStringWithEncoding(`a+`, ONIG_OPTION_IGNORECASE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, "xAa"); err != nil || actual != "Aa" {
+		t.Errorf("FindStringWithEncoding = %q, %v; want %q", actual, err, "Aa")
+	}
+	if actual, err := GsubWithEncoding(`(?<x>b)`, ONIG_OPTION_IGNORECASE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, "aBc", "<\\k<x>>"); err != nil || actual != "a<B>c" {
+		t.Errorf("GsubWithEncoding = %q, %v; want %q", actual, err, "a<B>c")
+	}
+	if _, err := MatchStringWithEncoding(`(`, ONIG_OPTION_NONE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_DEFAULT, ""); err == nil {
+		t.Errorf("expected a compile error")
+	}
+	if !Features().Oniguruma {
+		// builds without cgo only have Ruby syntax
+		return
+	}
+	if actual, err := FindStringWithEncoding(`\Qa.b\E`, ONIG_OPTION_NONE, ONIG_SYNTAX_PERL, ONIG_ENCODING_UTF8, "axb a.b"); err != nil || actual != "a.b" {
+		t.Errorf("FindStringWithEncoding with ONIG_SYNTAX_PERL = %q, %v; want %q", actual, err, "a.b")
+	}
+}
@@ -0,0 +1,309 @@
+package rubex
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// runeSet is a set of runes as sorted, non-overlapping, non-adjacent
+// [lo, hi] pairs.
+type runeSet []rune
+
+func (s runeSet) contains(r rune) bool {
+	i := sort.Search(len(s)/2, func(i int) bool { return s[2*i+1] >= r })
+	return i < len(s)/2 && s[2*i] <= r
+}
+
+// normalizeRuneSet sorts and merges arbitrary [lo, hi] pairs.
+func normalizeRuneSet(pairs []rune) runeSet {
+	n := len(pairs) / 2
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return pairs[2*idx[a]] < pairs[2*idx[b]] })
+	set := make(runeSet, 0, len(pairs))
+	for _, i := range idx {
+		lo, hi := pairs[2*i], pairs[2*i+1]
+		if k := len(set); k > 0 && lo <= set[k-1]+1 {
+			if hi > set[k-1] {
+				set[k-1] = hi
+			}
+			continue
+		}
+		set = append(set, lo, hi)
+	}
+	return set
+}
+
+func (s runeSet) union(t runeSet) runeSet {
+	pairs := make([]rune, 0, len(s)+len(t))
+	return normalizeRuneSet(append(append(pairs, s...), t...))
+}
+
+func (s runeSet) negate() runeSet {
+	neg := make(runeSet, 0, len(s)+2)
+	next := rune(0)
+	for i := 0; i < len(s); i += 2 {
+		if s[i] > next {
+			neg = append(neg, next, s[i]-1)
+		}
+		next = s[i+1] + 1
+	}
+	if next <= unicode.MaxRune {
+		neg = append(neg, next, unicode.MaxRune)
+	}
+	return neg
+}
+
+func (s runeSet) intersect(t runeSet) runeSet {
+	return s.negate().union(t.negate()).negate()
+}
+
+// fold adds the simple case folds of every rune in the set, which is what
+// case-insensitive matching of a class means.
+func (s runeSet) fold() runeSet {
+	pairs := append([]rune(nil), s...)
+	for i := 0; i < len(s); i += 2 {
+		lo, hi := s[i], s[i+1]
+		if lo < minFold {
+			lo = minFold
+		}
+		if hi > maxFold {
+			hi = maxFold
+		}
+		for r := lo; r <= hi; r++ {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				pairs = append(pairs, f, f)
+			}
+		}
+	}
+	return normalizeRuneSet(pairs)
+}
+
+// the range of runes that have case folds
+const (
+	minFold = 0x0041
+	maxFold = 0x1e943
+)
+
+func tableRuneSet(tables ...*unicode.RangeTable) runeSet {
+	var pairs []rune
+	for _, table := range tables {
+		for _, r := range table.R16 {
+			if r.Stride == 1 {
+				pairs = append(pairs, rune(r.Lo), rune(r.Hi))
+				continue
+			}
+			for c := rune(r.Lo); c <= rune(r.Hi); c += rune(r.Stride) {
+				pairs = append(pairs, c, c)
+			}
+		}
+		for _, r := range table.R32 {
+			if r.Stride == 1 {
+				pairs = append(pairs, rune(r.Lo), rune(r.Hi))
+				continue
+			}
+			for c := rune(r.Lo); c <= rune(r.Hi); c += rune(r.Stride) {
+				pairs = append(pairs, c, c)
+			}
+		}
+	}
+	return normalizeRuneSet(pairs)
+}
+
+// A charProperty is a named set of runes: a \p{...} property, a POSIX bracket
+// or the set behind \d, \s, \w and \h. Where the set is a union of general
+// categories, or a script, the converters can name it instead of listing it.
+type charProperty struct {
+	name       string
+	categories []string
+	script     string
+	extra      runeSet
+	build      func() runeSet
+
+	once sync.Once
+	set  runeSet
+}
+
+func (p *charProperty) runes() runeSet {
+	p.once.Do(func() {
+		switch {
+		case p.build != nil:
+			p.set = p.build()
+		case p.script != "":
+			p.set = tableRuneSet(unicode.Scripts[p.script])
+		default:
+			tables := make([]*unicode.RangeTable, 0, len(p.categories))
+			for _, c := range p.categories {
+				tables = append(tables, unicode.Categories[c])
+			}
+			p.set = tableRuneSet(tables...).union(p.extra)
+		}
+	})
+	return p.set
+}
+
+func categoryProperty(name string, extra runeSet, categories ...string) *charProperty {
+	return &charProperty{name: name, categories: categories, extra: extra}
+}
+
+// The sets Oniguruma uses for Unicode text.
+var (
+	propDigit  = categoryProperty("Digit", nil, "Nd")
+	propSpace  = categoryProperty("Space", runeSet{0x09, 0x0d, 0x85, 0x85}, "Zs", "Zl", "Zp")
+	propWord   = categoryProperty("Word", nil, "L", "M", "N", "Pc")
+	propXDigit = &charProperty{name: "XDigit", build: func() runeSet { return runeSet{'0', '9', 'A', 'F', 'a', 'f'} }}
+	propAny    = &charProperty{name: "Any", build: func() runeSet { return runeSet{0, unicode.MaxRune} }}
+)
+
+var posixProperties = map[string]*charProperty{
+	"alnum":  categoryProperty("Alnum", nil, "L", "M", "Nd"),
+	"alpha":  categoryProperty("Alpha", nil, "L", "M"),
+	"ascii":  {name: "ASCII", build: func() runeSet { return runeSet{0, 0x7f} }},
+	"blank":  categoryProperty("Blank", runeSet{0x09, 0x09}, "Zs"),
+	"cntrl":  {name: "Cntrl", build: buildCntrl},
+	"digit":  propDigit,
+	"graph":  {name: "Graph", build: buildGraph},
+	"lower":  categoryProperty("Lower", nil, "Ll"),
+	"print":  {name: "Print", build: func() runeSet { return buildGraph().union(tableRuneSet(unicode.Zs)) }},
+	"punct":  categoryProperty("Punct", runeSet{'$', '$', '+', '+', '<', '>', '^', '^', '`', '`', '|', '|', '~', '~'}, "P"),
+	"space":  propSpace,
+	"upper":  categoryProperty("Upper", nil, "Lu"),
+	"xdigit": propXDigit,
+	"word":   propWord,
+}
+
+// unassigned runes are those in no general category
+func unassignedRunes() runeSet {
+	tables := make([]*unicode.RangeTable, 0, len(unicode.Categories))
+	for name, table := range unicode.Categories {
+		if len(name) == 1 {
+			tables = append(tables, table)
+		}
+	}
+	return tableRuneSet(tables...).negate()
+}
+
+func buildCntrl() runeSet {
+	return tableRuneSet(unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs).union(unassignedRunes())
+}
+
+func buildGraph() runeSet {
+	return propSpace.runes().union(tableRuneSet(unicode.Cc, unicode.Cs)).union(unassignedRunes()).negate()
+}
+
+// long names of the general categories, as accepted by \p{...}
+var categoryLongNames = map[string]string{
+	"letter": "L", "casedletter": "LC", "uppercaseletter": "Lu", "lowercaseletter": "Ll",
+	"titlecaseletter": "Lt", "modifierletter": "Lm", "otherletter": "Lo",
+	"mark": "M", "nonspacingmark": "Mn", "spacingmark": "Mc", "enclosingmark": "Me",
+	"number": "N", "decimalnumber": "Nd", "letternumber": "Nl", "othernumber": "No",
+	"punctuation": "P", "connectorpunctuation": "Pc", "dashpunctuation": "Pd",
+	"openpunctuation": "Ps", "closepunctuation": "Pe", "initialpunctuation": "Pi",
+	"finalpunctuation": "Pf", "otherpunctuation": "Po",
+	"symbol": "S", "mathsymbol": "Sm", "currencysymbol": "Sc", "modifiersymbol": "Sk", "othersymbol": "So",
+	"separator": "Z", "spaceseparator": "Zs", "lineseparator": "Zl", "paragraphseparator": "Zp",
+	"other": "C", "control": "Cc", "format": "Cf", "surrogate": "Cs", "privateuse": "Co",
+}
+
+var (
+	namedProperties     map[string]*charProperty
+	namedPropertiesOnce sync.Once
+)
+
+// normalizePropertyName folds a property name the way Oniguruma compares
+// them: case, spaces, hyphens and underscores do not matter.
+func normalizePropertyName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// lookupProperty finds the set named in \p{name}.
+func lookupProperty(name string) *charProperty {
+	namedPropertiesOnce.Do(func() {
+		namedProperties = make(map[string]*charProperty)
+		for key, p := range posixProperties {
+			namedProperties[key] = p
+		}
+		namedProperties["any"] = propAny
+		for c := range unicode.Categories {
+			namedProperties[normalizePropertyName(c)] = categoryProperty(c, nil, c)
+		}
+		namedProperties["lc"] = categoryProperty("LC", nil, "Lu", "Ll", "Lt")
+		for long, c := range categoryLongNames {
+			namedProperties[long] = namedProperties[normalizePropertyName(c)]
+		}
+		for script := range unicode.Scripts {
+			namedProperties[normalizePropertyName(script)] = &charProperty{name: script, script: script}
+		}
+	})
+	return namedProperties[normalizePropertyName(name)]
+}
+
+// A charClass is a bracket expression, or one of the escapes that stand for
+// a set of runes.
+type charClass struct {
+	negate bool
+	items  []classItem
+	// and holds the operands of && intersections, which apply to the union
+	// of items
+	and []*charClass
+}
+
+// A classItem is a range, a property, possibly negated, or a nested class.
+type classItem struct {
+	lo, hi rune
+	prop   *charProperty
+	negate bool
+	class  *charClass
+}
+
+// runes returns the set of runes the class matches, before case folding.
+func (c *charClass) runes() runeSet {
+	var pairs []rune
+	for _, item := range c.items {
+		switch {
+		case item.class != nil:
+			pairs = append(pairs, item.class.runes()...)
+		case item.prop != nil && item.negate:
+			pairs = append(pairs, item.prop.runes().negate()...)
+		case item.prop != nil:
+			pairs = append(pairs, item.prop.runes()...)
+		default:
+			pairs = append(pairs, item.lo, item.hi)
+		}
+	}
+	set := normalizeRuneSet(pairs)
+	for _, and := range c.and {
+		set = set.intersect(and.runes())
+	}
+	if c.negate {
+		set = set.negate()
+	}
+	return set
+}
+
+// foldedRunes returns the set the class matches when case is ignored. The
+// folds are added before a negated class is inverted, so (?i)[^a] matches
+// neither a nor A.
+func (c *charClass) foldedRunes() runeSet {
+	if !c.negate {
+		return c.runes().fold()
+	}
+	positive := *c
+	positive.negate = false
+	return positive.runes().fold().negate()
+}
+
+// propertyClass is the class for \d, \p{L} and the like.
+func propertyClass(prop *charProperty, negate bool) *charClass {
+	return &charClass{items: []classItem{{prop: prop, negate: negate}}}
+}
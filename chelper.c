@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 #include <stdlib.h>
 #include <stdio.h>
 #include <string.h>
@@ -6,39 +8,145 @@
 #endif
 #include "chelper.h"
 
-int NewOnigRegex( char *pattern, int pattern_length, int option,
-                  OnigRegex *regex, OnigRegion **region, OnigErrorInfo **error_info, char **error_buffer) {
+/* Indexed by the Syntax constants in syntax.go; keep the two in the same order. */
+static OnigSyntaxType *onig_syntaxes[] = {
+    ONIG_SYNTAX_RUBY,
+    ONIG_SYNTAX_PERL,
+    ONIG_SYNTAX_PERL_NT,
+    ONIG_SYNTAX_JAVA,
+    ONIG_SYNTAX_POSIX_BASIC,
+    ONIG_SYNTAX_POSIX_EXTENDED,
+    ONIG_SYNTAX_EMACS,
+    ONIG_SYNTAX_GREP,
+    ONIG_SYNTAX_GNU_REGEX,
+    ONIG_SYNTAX_ASIS,
+};
+
+OnigSyntaxType *GetOnigSyntax(int syntax) {
+    if (syntax < 0 || syntax >= (int) (sizeof(onig_syntaxes) / sizeof(onig_syntaxes[0]))) {
+        return NULL;
+    }
+    return onig_syntaxes[syntax];
+}
+
+/* Indexed by the Encoding constants in encoding.go; keep the two in the same order. */
+static OnigEncoding onig_encodings[] = {
+    ONIG_ENCODING_UTF8,
+    ONIG_ENCODING_ASCII,
+    ONIG_ENCODING_UTF16_BE,
+    ONIG_ENCODING_UTF16_LE,
+    ONIG_ENCODING_UTF32_BE,
+    ONIG_ENCODING_UTF32_LE,
+    ONIG_ENCODING_ISO_8859_1,
+    ONIG_ENCODING_ISO_8859_2,
+    ONIG_ENCODING_ISO_8859_3,
+    ONIG_ENCODING_ISO_8859_4,
+    ONIG_ENCODING_ISO_8859_5,
+    ONIG_ENCODING_ISO_8859_6,
+    ONIG_ENCODING_ISO_8859_7,
+    ONIG_ENCODING_ISO_8859_8,
+    ONIG_ENCODING_ISO_8859_9,
+    ONIG_ENCODING_ISO_8859_10,
+    ONIG_ENCODING_ISO_8859_11,
+    ONIG_ENCODING_ISO_8859_13,
+    ONIG_ENCODING_ISO_8859_14,
+    ONIG_ENCODING_ISO_8859_15,
+    ONIG_ENCODING_ISO_8859_16,
+    ONIG_ENCODING_EUC_JP,
+    ONIG_ENCODING_EUC_TW,
+    ONIG_ENCODING_EUC_KR,
+    ONIG_ENCODING_EUC_CN,
+    ONIG_ENCODING_SJIS,
+    ONIG_ENCODING_KOI8_R,
+    ONIG_ENCODING_CP1251,
+    ONIG_ENCODING_BIG5,
+    ONIG_ENCODING_GB18030,
+};
+
+#define NUM_ONIG_ENCODINGS ((int) (sizeof(onig_encodings) / sizeof(onig_encodings[0])))
+
+OnigEncoding GetOnigEncoding(int encoding) {
+    if (encoding < 0 || encoding >= NUM_ONIG_ENCODINGS) {
+        return NULL;
+    }
+    return onig_encodings[encoding];
+}
+
+/* Returns the byte length of the character starting at offset, never less
+ * than one nor more than what is left of the string. */
+int OnigCharLength(OnigEncoding encoding, void *str, int str_length, int offset) {
+    int len;
+    OnigUChar *p = (OnigUChar *) str + offset;
+
+    if (offset >= str_length) {
+        return 1;
+    }
+    len = ONIGENC_MBC_ENC_LEN(encoding, p);
+    if (len < 1) {
+        len = 1;
+    }
+    if (len > str_length - offset) {
+        len = str_length - offset;
+    }
+    return len;
+}
+
+/* Oniguruma builds some of its global tables lazily, which is not safe when
+ * several threads compile at once. Initialize the library and compile one
+ * pattern that touches the unicode property and case fold tables up front, so
+ * that later calls to onig_new only read shared state. */
+int InitOnig() {
+    int ret = ONIG_NORMAL;
+    OnigRegex regex;
+    OnigErrorInfo error_info;
+    char warmup[] = "(?i:a)\\p{Alpha}";
+
+#if ONIGURUMA_VERSION_MAJOR >= 6
+    ret = onig_initialize(onig_encodings, NUM_ONIG_ENCODINGS);
+#else
+    ret = onig_init();
+#endif
+    if (ret != ONIG_NORMAL) {
+        return ret;
+    }
+    ret = onig_new(&regex, (OnigUChar *) warmup, (OnigUChar *) (warmup + strlen(warmup)), ONIG_OPTION_DEFAULT, ONIG_ENCODING_UTF8, ONIG_SYNTAX_RUBY, &error_info);
+    if (ret == ONIG_NORMAL) {
+        onig_free(regex);
+    }
+    return ret;
+}
+
+int NewOnigRegex( char *pattern, int pattern_length, int option, OnigSyntaxType *syntax, OnigEncoding encoding,
+                  OnigRegex *regex, char *error_buffer) {
     int ret = ONIG_NORMAL;
     int error_msg_len = 0;
+    OnigErrorInfo error_info;
 
     OnigUChar *pattern_start = (OnigUChar *) pattern;
     OnigUChar *pattern_end = (OnigUChar *) (pattern + pattern_length);
 
-    *error_info = (OnigErrorInfo *) malloc(sizeof(OnigErrorInfo));
-    memset(*error_info, 0, sizeof(OnigErrorInfo));
-
-    *error_buffer = (char*) malloc(ONIG_MAX_ERROR_MESSAGE_LEN * sizeof(char));
+    memset(&error_info, 0, sizeof(OnigErrorInfo));
+    memset(error_buffer, 0, ONIG_MAX_ERROR_MESSAGE_LEN * sizeof(char));
 
-    memset(*error_buffer, 0, ONIG_MAX_ERROR_MESSAGE_LEN * sizeof(char));
-
-    *region = onig_region_new();
-
-    ret = onig_new_default(regex, pattern_start, pattern_end, (OnigOptionType)(option), *error_info);
+    ret = onig_new(regex, pattern_start, pattern_end, (OnigOptionType)(option), encoding, syntax, &error_info);
   
     if (ret != ONIG_NORMAL) {
-        error_msg_len = onig_error_code_to_str((unsigned char*)(*error_buffer), ret, *error_info);
+        error_msg_len = onig_error_code_to_str((unsigned char*)(error_buffer), ret, &error_info);
         if (error_msg_len >= ONIG_MAX_ERROR_MESSAGE_LEN) {
             error_msg_len = ONIG_MAX_ERROR_MESSAGE_LEN - 1;
         }
-        (*error_buffer)[error_msg_len] = '\0';
+        error_buffer[error_msg_len] = '\0';
     }
     return ret;
 }
 
+/* Each search gets its own region so that concurrent searches on the same
+ * regex never share mutable state. The result is the start of the match,
+ * ONIG_MISMATCH, or one of Oniguruma's error codes. */
 int SearchOnigRegex( void *str, int str_length, int offset, int option,
-                  OnigRegex regex, OnigRegion *region, OnigErrorInfo *error_info, char *error_buffer, int *captures, int *numCaptures) {
+                  OnigRegex regex, int *captures, int *numCaptures) {
     int ret = ONIG_MISMATCH;
-    int error_msg_len = 0;
+    OnigRegion *region;
 #ifdef BENCHMARK_CHELP
     struct timeval tim1, tim2;
     long t;
@@ -49,19 +157,17 @@ int SearchOnigRegex( void *str, int str_length, int offset, int option,
     OnigUChar *search_start = (OnigUChar *)(str_start + offset);
     OnigUChar *search_end = str_end;
 
+    region = onig_region_new();
+    if (region == NULL) {
+        return ONIGERR_MEMORY;
+    }
+
 #ifdef BENCHMARK_CHELP
     gettimeofday(&tim1, NULL);
 #endif
 
     ret = onig_search(regex, str_start, str_end, search_start, search_end, region, option);
-    if (ret < 0 && error_buffer != NULL) {
-        error_msg_len = onig_error_code_to_str((unsigned char*)(error_buffer), ret, error_info);
-        if (error_msg_len >= ONIG_MAX_ERROR_MESSAGE_LEN) {
-            error_msg_len = ONIG_MAX_ERROR_MESSAGE_LEN - 1;
-        }
-        error_buffer[error_msg_len] = '\0';
-    }
-    else if (captures != NULL) {
+    if (ret >= 0 && captures != NULL) {
         int i;
 		int count = 0;
         for (i = 0; i < region->num_regs; i++) {
@@ -77,9 +183,23 @@ int SearchOnigRegex( void *str, int str_length, int offset, int option,
     t = (tim2.tv_sec - tim1.tv_sec) * 1000000 + tim2.tv_usec - tim1.tv_usec;
     printf("%ld microseconds elapsed\n", t);
 #endif
+    onig_region_free(region, 1);
     return ret;
 }
 
+/* OnigErrorMessage writes the message of an error code that takes no
+ * parameters into error_buffer, which holds ONIG_MAX_ERROR_MESSAGE_LEN bytes. */
+void OnigErrorMessage(int code, char *error_buffer) {
+    int error_msg_len = onig_error_code_to_str((unsigned char*)(error_buffer), code);
+    if (error_msg_len < 0) {
+        error_msg_len = 0;
+    }
+    if (error_msg_len >= ONIG_MAX_ERROR_MESSAGE_LEN) {
+        error_msg_len = ONIG_MAX_ERROR_MESSAGE_LEN - 1;
+    }
+    error_buffer[error_msg_len] = '\0';
+}
+
 int MatchOnigRegex(void *str, int str_length, int offset, int option,
                   OnigRegex regex, OnigRegion *region) {
     int ret = ONIG_MISMATCH;
@@ -156,7 +276,7 @@ int name_callback(const UChar* name, const UChar* name_end,
 			groupInfo->nameBuffer[offset] = ';';
 			offset += 1;
 		} 
-		strncpy(&groupInfo->nameBuffer[offset], name, nameLen);
+		memcpy(&groupInfo->nameBuffer[offset], name, nameLen);
 	}
 	groupInfo->bufferOffset = newOffset;
 	if (ngroup_num > 0) {
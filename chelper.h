@@ -1,10 +1,20 @@
 #include <oniguruma.h>
 
-extern int NewOnigRegex( char *pattern, int pattern_length, int option,
-                                  OnigRegex *regex, OnigRegion **region, OnigErrorInfo **error_info, char **error_buffer);
+extern int InitOnig();
+
+extern OnigSyntaxType *GetOnigSyntax(int syntax);
+
+extern OnigEncoding GetOnigEncoding(int encoding);
+
+extern int OnigCharLength(OnigEncoding encoding, void *str, int str_length, int offset);
+
+extern int NewOnigRegex( char *pattern, int pattern_length, int option, OnigSyntaxType *syntax, OnigEncoding encoding,
+                                  OnigRegex *regex, char *error_buffer);
 
 extern int SearchOnigRegex( void *str, int str_length, int offset, int option,
-                                  OnigRegex regex, OnigRegion *region, OnigErrorInfo *error_info, char *error_buffer, int *captures, int *numCaptures);
+                                  OnigRegex regex, int *captures, int *numCaptures);
+
+extern void OnigErrorMessage(int code, char *error_buffer);
 
 extern int MatchOnigRegex( void *str, int str_length, int offset, int option,
                   OnigRegex regex, OnigRegion *region);
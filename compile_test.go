@@ -0,0 +1,83 @@
+package rubex
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const numStartupPatterns = 10000
+
+// startupPatterns imitates a large set of rewrite rules loaded at startup.
+var startupPatterns = func() []string {
+	patterns := make([]string, numStartupPatterns)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf(`^/(?<section>s%d)/(?<id>\d+)(?:\.(json|xml))?$|host-%d\.example\.(?:com|net)`, i, i)
+	}
+	return patterns
+}()
+
+func compileAll(patterns []string, workers int) ([]*Regexp, error) {
+	compiled := make([]*Regexp, len(patterns))
+	errs := make([]error, workers)
+	next := int64(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(patterns) {
+					return
+				}
+				re, err := Compile(patterns[i])
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				compiled[i] = re
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func TestParallelCompile(t *testing.T) {
+	compiled, err := compileAll(startupPatterns[:1000], 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, re := range compiled {
+		path := fmt.Sprintf("/s%d/42.json", i)
+		if m := re.FindStringSubmatch(path); len(m) != 3 || m[1] != fmt.Sprintf("s%d", i) || m[2] != "42" {
+			t.Errorf("%s.FindStringSubmatch(%q) = %q", re, path, m)
+		}
+		re.Free()
+	}
+}
+
+// BenchmarkCompileStartup compiles 10k patterns with one worker per P; run it
+// with -cpu 1,2,4,8 to see compile time scale with GOMAXPROCS.
+func BenchmarkCompileStartup(b *testing.B) {
+	workers := runtime.GOMAXPROCS(0)
+	for i := 0; i < b.N; i++ {
+		compiled, err := compileAll(startupPatterns, workers)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		for _, re := range compiled {
+			re.Free()
+		}
+		b.StartTimer()
+	}
+}
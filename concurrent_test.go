@@ -0,0 +1,117 @@
+package rubex
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// These tests share a single compiled pattern between goroutines and are
+// meant to be run with the race detector: go test -race
+
+const numConcurrentSearches = 50
+
+var sharedRegexp = MustCompile(`(?<word>[a-z]+)(?<digits>\d*)`)
+
+func runShared(t *testing.T, check func(i int) error) {
+	var wg sync.WaitGroup
+	errs := make(chan error, numConcurrentRuns)
+	for i := 0; i < numConcurrentRuns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < numConcurrentSearches; j++ {
+				if err := check(i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func concurrentInput(i int) string {
+	return strings.Repeat(fmt.Sprintf("abc%d ", i), i%7+1)
+}
+
+func TestConcurrentFindAllString(t *testing.T) {
+	runShared(t, func(i int) error {
+		input := concurrentInput(i)
+		expected := strings.Fields(input)
+		actual := sharedRegexp.FindAllString(input, -1)
+		if !reflect.DeepEqual(actual, expected) {
+			return fmt.Errorf("FindAllString(%q) = %q; want %q", input, actual, expected)
+		}
+		return nil
+	})
+}
+
+func TestConcurrentFindAllStringSubmatchIndex(t *testing.T) {
+	runShared(t, func(i int) error {
+		input := concurrentInput(i)
+		numWords := i%7 + 1
+		wordLen := len(fmt.Sprintf("abc%d ", i))
+		actual := sharedRegexp.FindAllStringSubmatchIndex(input, -1)
+		if len(actual) != numWords {
+			return fmt.Errorf("FindAllStringSubmatchIndex(%q) returned %d matches; want %d", input, len(actual), numWords)
+		}
+		for k, match := range actual {
+			start := k * wordLen
+			expected := []int{start, start + wordLen - 1, start, start + 3, start + 3, start + wordLen - 1}
+			if !reflect.DeepEqual(match, expected) {
+				return fmt.Errorf("match %d of %q = %v; want %v", k, input, match, expected)
+			}
+		}
+		return nil
+	})
+}
+
+func TestConcurrentFindStringSubmatch(t *testing.T) {
+	runShared(t, func(i int) error {
+		input := fmt.Sprintf("  xyz%d", i)
+		expected := []string{fmt.Sprintf("xyz%d", i), "xyz", fmt.Sprint(i)}
+		actual := sharedRegexp.FindStringSubmatch(input)
+		if !reflect.DeepEqual(actual, expected) {
+			return fmt.Errorf("FindStringSubmatch(%q) = %q; want %q", input, actual, expected)
+		}
+		return nil
+	})
+}
+
+func TestConcurrentMatchString(t *testing.T) {
+	runShared(t, func(i int) error {
+		input := strings.Repeat(" ", i)
+		if i%2 == 0 {
+			input += "a"
+		}
+		if sharedRegexp.MatchString(input) != (i%2 == 0) {
+			return fmt.Errorf("MatchString(%q) = %t; want %t", input, !(i%2 == 0), i%2 == 0)
+		}
+		return nil
+	})
+}
+
+func TestConcurrentReplaceAndGsub(t *testing.T) {
+	runShared(t, func(i int) error {
+		input := concurrentInput(i)
+		expected := strings.Repeat(fmt.Sprintf("%d-abc ", i), i%7+1)
+		actual := sharedRegexp.Gsub(input, "\\k<digits>-\\k<word>")
+		if actual != expected {
+			return fmt.Errorf("Gsub(%q) = %q; want %q", input, actual, expected)
+		}
+		actual = sharedRegexp.ReplaceAllStringFunc(input, func(m string) string {
+			return strings.TrimLeft(m, "abc") + "-abc"
+		})
+		if actual != expected {
+			return fmt.Errorf("ReplaceAllStringFunc(%q) = %q; want %q", input, actual, expected)
+		}
+		return nil
+	})
+}
@@ -22,6 +22,111 @@ const (
 	ONIG_NORMAL   = 0
 	ONIG_MISMATCH = -1
 
-	ONIG_MISMATCH_STR                = "mismatch"
-	ONIGERR_UNDEFINED_NAME_REFERENCE = -217
+	ONIG_MISMATCH_STR                 = "mismatch"
+	ONIGERR_MATCH_STACK_LIMIT_OVER    = -15
+	ONIGERR_RETRY_LIMIT_IN_MATCH_OVER = -17
+	ONIGERR_UNDEFINED_NAME_REFERENCE  = -217
+)
+
+const (
+	/* syntax (operators) */
+	ONIG_SYN_OP_VARIABLE_META_CHARACTERS   = (1 << 0)
+	ONIG_SYN_OP_DOT_ANYCHAR                = (1 << 1)  /* . */
+	ONIG_SYN_OP_ASTERISK_ZERO_INF          = (1 << 2)  /* * */
+	ONIG_SYN_OP_ESC_ASTERISK_ZERO_INF      = (1 << 3)  /* \* */
+	ONIG_SYN_OP_PLUS_ONE_INF               = (1 << 4)  /* + */
+	ONIG_SYN_OP_ESC_PLUS_ONE_INF           = (1 << 5)  /* \+ */
+	ONIG_SYN_OP_QMARK_ZERO_ONE             = (1 << 6)  /* ? */
+	ONIG_SYN_OP_ESC_QMARK_ZERO_ONE         = (1 << 7)  /* \? */
+	ONIG_SYN_OP_BRACE_INTERVAL             = (1 << 8)  /* {lower,upper} */
+	ONIG_SYN_OP_ESC_BRACE_INTERVAL         = (1 << 9)  /* \{lower,upper\} */
+	ONIG_SYN_OP_VBAR_ALT                   = (1 << 10) /* | */
+	ONIG_SYN_OP_ESC_VBAR_ALT               = (1 << 11) /* \| */
+	ONIG_SYN_OP_LPAREN_SUBEXP              = (1 << 12) /* (...) */
+	ONIG_SYN_OP_ESC_LPAREN_SUBEXP          = (1 << 13) /* \(...\) */
+	ONIG_SYN_OP_ESC_AZ_BUF_ANCHOR          = (1 << 14) /* \A, \Z, \z */
+	ONIG_SYN_OP_ESC_CAPITAL_G_BEGIN_ANCHOR = (1 << 15) /* \G */
+	ONIG_SYN_OP_DECIMAL_BACKREF            = (1 << 16) /* \num */
+	ONIG_SYN_OP_BRACKET_CC                 = (1 << 17) /* [...] */
+	ONIG_SYN_OP_ESC_W_WORD                 = (1 << 18) /* \w, \W */
+	ONIG_SYN_OP_ESC_LTGT_WORD_BEGIN_END    = (1 << 19) /* \<. \> */
+	ONIG_SYN_OP_ESC_B_WORD_BOUND           = (1 << 20) /* \b, \B */
+	ONIG_SYN_OP_ESC_S_WHITE_SPACE          = (1 << 21) /* \s, \S */
+	ONIG_SYN_OP_ESC_D_DIGIT                = (1 << 22) /* \d, \D */
+	ONIG_SYN_OP_LINE_ANCHOR                = (1 << 23) /* ^, $ */
+	ONIG_SYN_OP_POSIX_BRACKET              = (1 << 24) /* [:xxxx:] */
+	ONIG_SYN_OP_QMARK_NON_GREEDY           = (1 << 25) /* ??,*?,+?,{n,m}? */
+	ONIG_SYN_OP_ESC_CONTROL_CHARS          = (1 << 26) /* \n,\r,\t,\a ... */
+	ONIG_SYN_OP_ESC_C_CONTROL              = (1 << 27) /* \cx */
+	ONIG_SYN_OP_ESC_OCTAL3                 = (1 << 28) /* \OOO */
+	ONIG_SYN_OP_ESC_X_HEX2                 = (1 << 29) /* \xHH */
+	ONIG_SYN_OP_ESC_X_BRACE_HEX8           = (1 << 30) /* \x{7HHHHHHH} */
+	ONIG_SYN_OP_ESC_O_BRACE_OCTAL          = (1 << 31) /* \o{1OOOOOOOOOO} */
+
+	ONIG_SYN_OP2_ESC_CAPITAL_Q_QUOTE           = (1 << 0)  /* \Q...\E */
+	ONIG_SYN_OP2_QMARK_GROUP_EFFECT            = (1 << 1)  /* (?...) */
+	ONIG_SYN_OP2_OPTION_PERL                   = (1 << 2)  /* (?imsx),(?-imsx) */
+	ONIG_SYN_OP2_OPTION_RUBY                   = (1 << 3)  /* (?imx), (?-imx) */
+	ONIG_SYN_OP2_PLUS_POSSESSIVE_REPEAT        = (1 << 4)  /* ?+,*+,++ */
+	ONIG_SYN_OP2_PLUS_POSSESSIVE_INTERVAL      = (1 << 5)  /* {n,m}+ */
+	ONIG_SYN_OP2_CCLASS_SET_OP                 = (1 << 6)  /* [...&&..[..]..] */
+	ONIG_SYN_OP2_QMARK_LT_NAMED_GROUP          = (1 << 7)  /* (?<name>...) */
+	ONIG_SYN_OP2_ESC_K_NAMED_BACKREF           = (1 << 8)  /* \k<name> */
+	ONIG_SYN_OP2_ESC_G_SUBEXP_CALL             = (1 << 9)  /* \g<name>, \g<n> */
+	ONIG_SYN_OP2_ATMARK_CAPTURE_HISTORY        = (1 << 10) /* (?@..),(?@<x>..) */
+	ONIG_SYN_OP2_ESC_CAPITAL_C_BAR_CONTROL     = (1 << 11) /* \C-x */
+	ONIG_SYN_OP2_ESC_CAPITAL_M_BAR_META        = (1 << 12) /* \M-x */
+	ONIG_SYN_OP2_ESC_V_VTAB                    = (1 << 13) /* \v as VTAB */
+	ONIG_SYN_OP2_ESC_U_HEX4                    = (1 << 14) /* \uHHHH */
+	ONIG_SYN_OP2_ESC_GNU_BUF_ANCHOR            = (1 << 15) /* \`, \' */
+	ONIG_SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY     = (1 << 16) /* \p{...}, \P{...} */
+	ONIG_SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT    = (1 << 17) /* \p{^..}, \P{^..} */
+	ONIG_SYN_OP2_ESC_H_XDIGIT                  = (1 << 19) /* \h, \H */
+	ONIG_SYN_OP2_INEFFECTIVE_ESCAPE            = (1 << 20) /* \ */
+	ONIG_SYN_OP2_QMARK_LPAREN_IF_ELSE          = (1 << 21) /* (?(n)) (?(...)...|...) */
+	ONIG_SYN_OP2_ESC_CAPITAL_K_KEEP            = (1 << 22) /* \K */
+	ONIG_SYN_OP2_ESC_CAPITAL_R_GENERAL_NEWLINE = (1 << 23) /* \R \r\n else [\x0a-\x0d] */
+	ONIG_SYN_OP2_ESC_CAPITAL_N_O_SUPER_DOT     = (1 << 24) /* \N (?-m:.), \O (?m:.) */
+	ONIG_SYN_OP2_QMARK_TILDE_ABSENT_GROUP      = (1 << 25) /* (?~...) */
+	ONIG_SYN_OP2_ESC_X_Y_TEXT_SEGMENT          = (1 << 26) /* \X \y \Y */
+	ONIG_SYN_OP2_QMARK_PERL_SUBEXP_CALL        = (1 << 27) /* (?R), (?&name)... */
+	ONIG_SYN_OP2_QMARK_BRACE_CALLOUT_CONTENTS  = (1 << 28) /* (?{...}) (?{{...}}) */
+	ONIG_SYN_OP2_ASTERISK_CALLOUT_NAME         = (1 << 29) /* (*name) (*name{a,..}) */
+	ONIG_SYN_OP2_OPTION_ONIGURUMA              = (1 << 30) /* (?imxWDSPy) */
+	ONIG_SYN_OP2_QMARK_CAPITAL_P_NAME          = (1 << 31) /* (?P<name>...) (?P=name) */
+
+	/* syntax (behavior) */
+	ONIG_SYN_CONTEXT_INDEP_REPEAT_OPS        = (1 << 0)  /* ?, *, +, {n,m} */
+	ONIG_SYN_CONTEXT_INVALID_REPEAT_OPS      = (1 << 1)  /* error or ignore */
+	ONIG_SYN_ALLOW_UNMATCHED_CLOSE_SUBEXP    = (1 << 2)  /* ...)... */
+	ONIG_SYN_ALLOW_INVALID_INTERVAL          = (1 << 3)  /* {??? */
+	ONIG_SYN_ALLOW_INTERVAL_LOW_ABBREV       = (1 << 4)  /* {,n} => {0,n} */
+	ONIG_SYN_STRICT_CHECK_BACKREF            = (1 << 5)  /* /(\1)/,/\1()/ ..*/
+	ONIG_SYN_DIFFERENT_LEN_ALT_LOOK_BEHIND   = (1 << 6)  /* (?<=a|bc) */
+	ONIG_SYN_CAPTURE_ONLY_NAMED_GROUP        = (1 << 7)  /* see doc/RE */
+	ONIG_SYN_ALLOW_MULTIPLEX_DEFINITION_NAME = (1 << 8)  /* (?<x>)(?<x>) */
+	ONIG_SYN_FIXED_INTERVAL_IS_GREEDY_ONLY   = (1 << 9)  /* a{n}?=(?:a{n})? */
+	ONIG_SYN_ISOLATED_OPTION_CONTINUE_BRANCH = (1 << 10) /* ..(?i)...|... */
+	ONIG_SYN_VARIABLE_LEN_LOOK_BEHIND        = (1 << 11) /* (?<=a+|..) */
+	ONIG_SYN_PYTHON                          = (1 << 12) /* \UHHHHHHHH */
+	ONIG_SYN_WHOLE_OPTIONS                   = (1 << 13) /* (?Ie) */
+	ONIG_SYN_CONTEXT_INDEP_ANCHORS           = (1 << 31) /* not implemented */
+	/* syntax (behavior) in char class [...] */
+	ONIG_SYN_NOT_NEWLINE_IN_NEGATIVE_CC            = (1 << 20) /* [^...] */
+	ONIG_SYN_BACKSLASH_ESCAPE_IN_CC                = (1 << 21) /* [..\w..] etc.. */
+	ONIG_SYN_ALLOW_EMPTY_RANGE_IN_CC               = (1 << 22)
+	ONIG_SYN_ALLOW_DOUBLE_RANGE_OP_IN_CC           = (1 << 23) /* [0-9-a]=[0-9\-a] */
+	ONIG_SYN_ALLOW_INVALID_CODE_END_OF_RANGE_IN_CC = (1 << 26)
+	/* syntax (behavior) warning */
+	ONIG_SYN_WARN_CC_OP_NOT_ESCAPED       = (1 << 24) /* [,-,] */
+	ONIG_SYN_WARN_REDUNDANT_NESTED_REPEAT = (1 << 25) /* (?:a*)+ */
+
+	/* meta character specifiers (onig_set_meta_char()) */
+	ONIG_META_CHAR_ESCAPE           = 0
+	ONIG_META_CHAR_ANYCHAR          = 1
+	ONIG_META_CHAR_ANYTIME          = 2
+	ONIG_META_CHAR_ZERO_OR_ONE_TIME = 3
+	ONIG_META_CHAR_ONE_OR_MORE_TIME = 4
+	ONIG_META_CHAR_ANYCHAR_ANYTIME  = 5
+	ONIG_INEFFECTIVE_META_CHAR      = 0
 )
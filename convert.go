@@ -0,0 +1,486 @@
+package rubex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An Incompatibility is a construct in a pattern that the target syntax of a
+// conversion cannot express. Offset and End are byte offsets in the pattern.
+type Incompatibility struct {
+	Offset    int
+	End       int
+	Construct string
+}
+
+// ConversionError lists every construct that kept a pattern from being
+// converted.
+type ConversionError struct {
+	Pattern           string
+	Target            string
+	Incompatibilities []Incompatibility
+}
+
+func (e *ConversionError) Error() string {
+	problems := make([]string, 0, len(e.Incompatibilities))
+	for _, inc := range e.Incompatibilities {
+		problems = append(problems, fmt.Sprintf("%s at offset %d", inc.Construct, inc.Offset))
+	}
+	return fmt.Sprintf("rubex: cannot convert %q to %s: %s", e.Pattern, e.Target, strings.Join(problems, ", "))
+}
+
+// ToRE2 converts a Ruby-syntax pattern and its options to the syntax of Go's
+// regexp package. Backreferences, lookaround, atomic groups, possessive
+// quantifiers, subexpression calls, \G, \Z and repetition counts above 1000
+// cannot be expressed and are reported in a *ConversionError.
+//
+// A few differences remain: \b and \B only know ASCII word characters in
+// RE2, ^ also matches after a newline that ends the text, and ignoring case
+// uses simple case folding, so that ß no longer matches ss.
+func ToRE2(pattern string, option Option) (string, error) {
+	c, err := convert(pattern, option, "RE2")
+	if err != nil {
+		return "", err
+	}
+	return c.b.String(), nil
+}
+
+// ToJavaScript converts a Ruby-syntax pattern and its options to an
+// ECMAScript pattern and flags, for new RegExp(source, flags). Atomic groups,
+// possessive quantifiers, subexpression calls, \G and backreferences to
+// duplicate names cannot be expressed and are reported in a
+// *ConversionError. The result uses the u flag, and lookbehind, which
+// needs ES2018. As in RE2, ignoring case uses simple case folding.
+func ToJavaScript(pattern string, option Option) (source string, flags string, err error) {
+	c, err := convert(pattern, option, "JavaScript")
+	if err != nil {
+		return "", "", err
+	}
+	flags = "u"
+	if c.foldFlag {
+		flags = "iu"
+	}
+	return c.b.String(), flags, nil
+}
+
+// ToRE2 converts the pattern as by the package-level ToRE2.
+func (re *Regexp) ToRE2() (string, error) {
+	if err := re.checkConvertible(); err != nil {
+		return "", err
+	}
+	return ToRE2(re.pattern, re.Options())
+}
+
+// ToJavaScript converts the pattern as by the package-level ToJavaScript.
+func (re *Regexp) ToJavaScript() (source string, flags string, err error) {
+	if err := re.checkConvertible(); err != nil {
+		return "", "", err
+	}
+	return ToJavaScript(re.pattern, re.Options())
+}
+
+func (re *Regexp) checkConvertible() error {
+	if re.syntax != ONIG_SYNTAX_RUBY || re.encoding != ONIG_ENCODING_UTF8 {
+		return fmt.Errorf("rubex: only %s patterns on %s text can be converted", ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	}
+	return nil
+}
+
+type converter struct {
+	target   string
+	js       bool
+	parsed   *parsedPattern
+	foldFlag bool
+	b        strings.Builder
+	problems []Incompatibility
+}
+
+func convert(pattern string, option Option, target string) (*converter, error) {
+	parsed, err := parsePattern(pattern, option)
+	if err != nil {
+		return nil, err
+	}
+	c := &converter{target: target, js: target == "JavaScript", parsed: parsed}
+	for _, o := range []int{ONIG_OPTION_FIND_LONGEST, ONIG_OPTION_FIND_NOT_EMPTY} {
+		if option&Option(o) != 0 {
+			c.problems = append(c.problems, Incompatibility{0, 0, Option(o).String()})
+		}
+	}
+	// JavaScript has no inline (?i), so a case-insensitive part is spelled out
+	// unless the whole pattern ignores case
+	if c.js {
+		folds, exact := countFolds(parsed.root)
+		c.foldFlag = folds > 0 && exact == 0
+	}
+	c.write(parsed.root)
+	if len(c.problems) > 0 {
+		return nil, &ConversionError{Pattern: pattern, Target: target, Incompatibilities: c.problems}
+	}
+	return c, nil
+}
+
+// countFolds counts the case-insensitive and case-sensitive nodes for which
+// case matters.
+func countFolds(n *node) (folds int, exact int) {
+	switch n.op {
+	case opLiteral, opClass, opBackref:
+		if n.fold {
+			return 1, 0
+		}
+		return 0, 1
+	}
+	for _, sub := range n.subs {
+		f, e := countFolds(sub)
+		folds += f
+		exact += e
+	}
+	return
+}
+
+func (c *converter) unsupported(n *node, construct string) {
+	c.problems = append(c.problems, Incompatibility{n.pos, n.end, construct})
+}
+
+// jsWordClass is Oniguruma's \w, which JavaScript's \b does not use.
+const jsWordClass = `[\p{L}\p{M}\p{N}\p{Pc}]`
+
+func (c *converter) write(n *node) {
+	switch n.op {
+	case opEmpty:
+	case opLiteral:
+		c.writeLiteral(n)
+	case opClass:
+		c.writeClass(n.class, n.fold)
+	case opAnyChar:
+		switch {
+		case !n.dotall:
+			c.b.WriteString(`[^\n]`)
+		case c.js:
+			c.b.WriteString(`[\s\S]`)
+		default:
+			c.b.WriteString(`(?s:.)`)
+		}
+	case opBeginLine:
+		if c.js {
+			c.b.WriteString(`(?:(?<![\s\S])|(?<=\n)(?=[\s\S]))`)
+		} else {
+			c.b.WriteString(`(?m:^)`)
+		}
+	case opEndLine:
+		if c.js {
+			c.b.WriteString(`(?=\n|(?![\s\S]))`)
+		} else {
+			c.b.WriteString(`(?m:$)`)
+		}
+	case opBeginText:
+		if c.js {
+			c.b.WriteString(`(?<![\s\S])`)
+		} else {
+			c.b.WriteString(`\A`)
+		}
+	case opEndText:
+		if c.js {
+			c.b.WriteString(`(?![\s\S])`)
+		} else {
+			c.b.WriteString(`\z`)
+		}
+	case opEndTextNewline:
+		if c.js {
+			c.b.WriteString(`(?=\n?(?![\s\S]))`)
+		} else {
+			c.unsupported(n, `end of text before a newline (\Z)`)
+		}
+	case opWordBoundary:
+		if c.js {
+			c.b.WriteString(`(?:(?<=` + jsWordClass + `)(?!` + jsWordClass + `)|(?<!` + jsWordClass + `)(?=` + jsWordClass + `))`)
+		} else {
+			c.b.WriteString(`\b`)
+		}
+	case opNoWordBoundary:
+		if c.js {
+			c.b.WriteString(`(?:(?<=` + jsWordClass + `)(?=` + jsWordClass + `)|(?<!` + jsWordClass + `)(?!` + jsWordClass + `))`)
+		} else {
+			c.b.WriteString(`\B`)
+		}
+	case opSearchStart:
+		c.unsupported(n, `search start anchor (\G)`)
+	case opConcat:
+		for _, sub := range n.subs {
+			c.write(sub)
+		}
+	case opAlternate:
+		c.b.WriteString("(?:")
+		for i, sub := range n.subs {
+			if i > 0 {
+				c.b.WriteByte('|')
+			}
+			c.write(sub)
+		}
+		c.b.WriteByte(')')
+	case opCapture:
+		switch {
+		case n.name == "":
+			c.b.WriteByte('(')
+		case len(c.parsed.names[n.name]) > 1:
+			c.unsupported(n, "duplicate group name <"+n.name+">")
+		case c.js:
+			c.b.WriteString("(?<" + n.name + ">")
+		default:
+			c.b.WriteString("(?P<" + n.name + ">")
+		}
+		c.write(n.subs[0])
+		c.b.WriteByte(')')
+	case opGroup:
+		c.b.WriteString("(?:")
+		c.write(n.subs[0])
+		c.b.WriteByte(')')
+	case opRepeat:
+		c.writeRepeat(n)
+	case opLookahead, opNegLookahead, opLookbehind, opNegLookbehind:
+		if !c.js {
+			c.unsupported(n, lookaroundNames[n.op])
+		}
+		c.b.WriteString(lookaroundOpeners[n.op])
+		c.write(n.subs[0])
+		c.b.WriteByte(')')
+	case opAtomic:
+		c.unsupported(n, "atomic group")
+		c.write(n.subs[0])
+	case opBackref:
+		switch {
+		case !c.js:
+			c.unsupported(n, "backreference")
+		case len(n.refs) > 1:
+			c.unsupported(n, "backreference to duplicate name <"+n.name+">")
+		case n.fold && !c.foldFlag:
+			c.unsupported(n, "case-insensitive backreference")
+		case n.name != "":
+			c.b.WriteString(`\k<` + n.name + `>`)
+		default:
+			c.b.WriteString(`(?:\` + strconv.Itoa(n.refs[0]) + `)`)
+		}
+	case opCall:
+		c.unsupported(n, "subexpression call")
+	case opUnsupported:
+		c.unsupported(n, n.name)
+	}
+}
+
+var lookaroundNames = map[nodeOp]string{
+	opLookahead:     "lookahead",
+	opNegLookahead:  "negative lookahead",
+	opLookbehind:    "lookbehind",
+	opNegLookbehind: "negative lookbehind",
+}
+
+var lookaroundOpeners = map[nodeOp]string{
+	opLookahead:     "(?=",
+	opNegLookahead:  "(?!",
+	opLookbehind:    "(?<=",
+	opNegLookbehind: "(?<!",
+}
+
+func (c *converter) writeRepeat(n *node) {
+	if n.possessive {
+		c.unsupported(n, "possessive quantifier")
+	}
+	if !c.js && (n.min > 1000 || n.max > 1000) {
+		c.unsupported(n, "repetition count above 1000")
+	}
+	c.b.WriteString("(?:")
+	c.write(n.subs[0])
+	c.b.WriteByte(')')
+	switch {
+	case n.min == 0 && n.max < 0:
+		c.b.WriteByte('*')
+	case n.min == 1 && n.max < 0:
+		c.b.WriteByte('+')
+	case n.min == 0 && n.max == 1:
+		c.b.WriteByte('?')
+	case n.min == n.max:
+		c.b.WriteString("{" + strconv.Itoa(n.min) + "}")
+	case n.max < 0:
+		c.b.WriteString("{" + strconv.Itoa(n.min) + ",}")
+	default:
+		c.b.WriteString("{" + strconv.Itoa(n.min) + "," + strconv.Itoa(n.max) + "}")
+	}
+	if n.lazy {
+		c.b.WriteByte('?')
+	}
+}
+
+func (c *converter) writeLiteral(n *node) {
+	switch {
+	case !n.fold || c.foldFlag:
+		for _, r := range n.runes {
+			c.writeRune(r, false)
+		}
+	case !c.js:
+		c.b.WriteString("(?i:")
+		for _, r := range n.runes {
+			c.writeRune(r, false)
+		}
+		c.b.WriteByte(')')
+	default:
+		for _, r := range n.runes {
+			if unicode.SimpleFold(r) == r {
+				c.writeRune(r, false)
+				continue
+			}
+			c.b.WriteByte('[')
+			for f := r; ; {
+				c.writeRune(f, true)
+				if f = unicode.SimpleFold(f); f == r {
+					break
+				}
+			}
+			c.b.WriteByte(']')
+		}
+	}
+}
+
+func (c *converter) writeClass(class *charClass, fold bool) {
+	if fold && !c.foldFlag && c.js {
+		// spell out the case folds
+		c.writeRuneSet(class.foldedRunes(), false)
+		return
+	}
+	if fold && !c.foldFlag {
+		c.b.WriteString("(?i:")
+		defer c.b.WriteByte(')')
+	}
+	// a lone property such as \d is written by name where possible, and a
+	// negated one such as \W as a negated class
+	if len(class.items) == 1 && len(class.and) == 0 && class.items[0].prop != nil && !class.negate {
+		item := class.items[0]
+		switch {
+		case item.prop.script != "" || len(item.prop.categories) == 1 && item.prop.extra == nil:
+			c.writeProperty(item.prop, item.negate)
+			return
+		case item.negate:
+			c.writeClassItems(&charClass{negate: true, items: []classItem{{prop: item.prop}}})
+			return
+		}
+	}
+	c.writeClassItems(class)
+}
+
+// writeClassItems writes a class item by item where the target can name its
+// properties, and as a list of ranges otherwise.
+func (c *converter) writeClassItems(class *charClass) {
+	items, ok := flattenClass(class)
+	if !ok {
+		c.writeRuneSet(class.runes(), false)
+		return
+	}
+	c.b.WriteByte('[')
+	if class.negate {
+		c.b.WriteByte('^')
+	}
+	for _, item := range items {
+		switch {
+		case item.prop == nil:
+			c.writeRune(item.lo, true)
+			if item.hi != item.lo {
+				c.b.WriteByte('-')
+				c.writeRune(item.hi, true)
+			}
+		case item.prop.script != "" || len(item.prop.categories) == 1 && item.prop.extra == nil:
+			c.writeProperty(item.prop, item.negate)
+		case item.negate || item.prop.build != nil:
+			set := item.prop.runes()
+			if item.negate {
+				set = set.negate()
+			}
+			c.writeRanges(set)
+		default:
+			for _, category := range item.prop.categories {
+				c.b.WriteString(`\p{` + category + `}`)
+			}
+			c.writeRanges(item.prop.extra)
+		}
+	}
+	c.b.WriteByte(']')
+}
+
+// flattenClass returns the items of a class with the nested classes merged
+// in, if that does not change its meaning.
+func flattenClass(class *charClass) ([]classItem, bool) {
+	if len(class.and) > 0 {
+		return nil, false
+	}
+	var items []classItem
+	for _, item := range class.items {
+		if item.class == nil {
+			items = append(items, item)
+			continue
+		}
+		if item.class.negate {
+			return nil, false
+		}
+		nested, ok := flattenClass(item.class)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, nested...)
+	}
+	return items, true
+}
+
+func (c *converter) writeProperty(prop *charProperty, negate bool) {
+	name := prop.name
+	if len(prop.categories) == 1 {
+		name = prop.categories[0]
+	}
+	if prop.script != "" && c.js {
+		name = "Script=" + prop.script
+	}
+	if negate {
+		c.b.WriteString(`\P{` + name + `}`)
+	} else {
+		c.b.WriteString(`\p{` + name + `}`)
+	}
+}
+
+func (c *converter) writeRuneSet(set runeSet, negate bool) {
+	c.b.WriteByte('[')
+	if negate {
+		c.b.WriteByte('^')
+	}
+	c.writeRanges(set)
+	c.b.WriteByte(']')
+}
+
+func (c *converter) writeRanges(set runeSet) {
+	for i := 0; i < len(set); i += 2 {
+		c.writeRune(set[i], true)
+		if set[i+1] != set[i] {
+			c.b.WriteByte('-')
+			c.writeRune(set[i+1], true)
+		}
+	}
+}
+
+// writeRune writes r so that it stands for itself, in a class or outside.
+func (c *converter) writeRune(r rune, inClass bool) {
+	special := `\.+*?()|[]{}^$`
+	if inClass {
+		special = `\[]^-`
+	}
+	if c.js {
+		special += "/"
+	}
+	switch {
+	case r < 0x80 && strings.ContainsRune(special, r):
+		c.b.WriteByte('\\')
+		c.b.WriteRune(r)
+	case r >= ' ' && r < 0x7f || r >= 0x80 && unicode.IsPrint(r):
+		c.b.WriteRune(r)
+	case c.js:
+		c.b.WriteString(`\u{` + strconv.FormatInt(int64(r), 16) + `}`)
+	default:
+		c.b.WriteString(`\x{` + strconv.FormatInt(int64(r), 16) + `}`)
+	}
+}
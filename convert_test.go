@@ -0,0 +1,129 @@
+package rubex
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var conversionTests = []struct {
+	pattern string
+	option  Option
+	re2     string
+	js      string
+	jsFlags string
+}{
+	{`abc`, ONIG_OPTION_NONE, `abc`, `abc`, "u"},
+	{`a.b`, ONIG_OPTION_NONE, `a[^\n]b`, `a[^\n]b`, "u"},
+	{`a.b`, ONIG_OPTION_MULTILINE, `a(?s:.)b`, `a[\s\S]b`, "u"},
+	{`^a$`, ONIG_OPTION_NONE, `(?m:^)a(?m:$)`, `(?:(?<![\s\S])|(?<=\n)(?=[\s\S]))a(?=\n|(?![\s\S]))`, "u"},
+	{`\Aa\z`, ONIG_OPTION_NONE, `\Aa\z`, `(?<![\s\S])a(?![\s\S])`, "u"},
+	{`(?<year>\d{4})-(\d\d)`, ONIG_OPTION_NONE, `(?P<year>(?:\p{Nd}){4})-(?:\p{Nd}\p{Nd})`, `(?<year>(?:\p{Nd}){4})-(?:\p{Nd}\p{Nd})`, "u"},
+	{`a+?b*c?d{2,}e{,3}`, ONIG_OPTION_NONE, `(?:a)+?(?:b)*(?:c)?(?:d){2,}(?:e){0,3}`, `(?:a)+?(?:b)*(?:c)?(?:d){2,}(?:e){0,3}`, "u"},
+	{`[a-z&&[^aeiou]]`, ONIG_OPTION_NONE, `[b-df-hj-np-tv-z]`, `[b-df-hj-np-tv-z]`, "u"},
+	{`[^\s\d_]`, ONIG_OPTION_NONE, `[^\p{Zs}\p{Zl}\p{Zp}\x{9}-\x{d}\x{85}\p{Nd}_]`, `[^\p{Zs}\p{Zl}\p{Zp}\u{9}-\u{d}\u{85}\p{Nd}_]`, "u"},
+	{`\W`, ONIG_OPTION_NONE, `[^\p{L}\p{M}\p{N}\p{Pc}]`, `[^\p{L}\p{M}\p{N}\p{Pc}]`, "u"},
+	{`\p{Greek}+`, ONIG_OPTION_NONE, `(?:\p{Greek})+`, `(?:\p{Script=Greek})+`, "u"},
+	{`(?i)ab`, ONIG_OPTION_NONE, `(?i:ab)`, `ab`, "iu"},
+	{`a(?i:b)`, ONIG_OPTION_NONE, `a(?:(?i:b))`, `a(?:[bB])`, "u"},
+	{`a(?i:[a-c])`, ONIG_OPTION_NONE, `a(?:(?i:[a-c]))`, `a(?:[A-Ca-c])`, "u"},
+	{`(a)(?=b)\1`, ONIG_OPTION_NONE, "", `(a)(?=b)(?:\1)`, "u"},
+	{`(?<x>a)\k<x>/`, ONIG_OPTION_NONE, "", `(?<x>a)\k<x>\/`, "u"},
+	{`a\Z`, ONIG_OPTION_NONE, "", `a(?=\n?(?![\s\S]))`, "u"},
+	{`a|b(?#comment)|`, ONIG_OPTION_NONE, `(?:a|b|)`, `(?:a|b|)`, "u"},
+	{"a # comment\n b", ONIG_OPTION_EXTEND, `ab`, `ab`, "u"},
+	{`\x41\u00e9\t`, ONIG_OPTION_NONE, `Aé\x{9}`, `Aé\u{9}`, "u"},
+	{`[[:alpha:]]`, ONIG_OPTION_NONE, `[\p{L}\p{M}]`, `[\p{L}\p{M}]`, "u"},
+}
+
+func TestConversions(t *testing.T) {
+	for _, tc := range conversionTests {
+		re2, err := ToRE2(tc.pattern, tc.option)
+		if tc.re2 == "" {
+			if _, ok := err.(*ConversionError); !ok {
+				t.Errorf("ToRE2(%q) = %q, %v; want a conversion error", tc.pattern, re2, err)
+			}
+		} else if err != nil || re2 != tc.re2 {
+			t.Errorf("ToRE2(%q) = %q, %v; want %q", tc.pattern, re2, err, tc.re2)
+		}
+		js, flags, err := ToJavaScript(tc.pattern, tc.option)
+		if err != nil || js != tc.js || flags != tc.jsFlags {
+			t.Errorf("ToJavaScript(%q) = %q, %q, %v; want %q, %q", tc.pattern, js, flags, err, tc.js, tc.jsFlags)
+		}
+	}
+}
+
+var conversionErrorTests = []struct {
+	pattern string
+	re2     []Incompatibility
+	js      []Incompatibility
+}{
+	{`(a)\1`, []Incompatibility{{3, 5, "backreference"}}, nil},
+	{`(?<=a)b(?!c)`, []Incompatibility{{0, 6, "lookbehind"}, {7, 12, "negative lookahead"}}, nil},
+	{`(?>a+)b`, []Incompatibility{{0, 6, "atomic group"}}, []Incompatibility{{0, 6, "atomic group"}}},
+	{`xa*+b`, []Incompatibility{{1, 4, "possessive quantifier"}}, []Incompatibility{{1, 4, "possessive quantifier"}}},
+	{`(?<n>a|\g<n>)`, []Incompatibility{{7, 12, "subexpression call"}}, []Incompatibility{{7, 12, "subexpression call"}}},
+	{`\Ga{1001}`, []Incompatibility{{0, 2, `search start anchor (\G)`}, {2, 9, "repetition count above 1000"}}, []Incompatibility{{0, 2, `search start anchor (\G)`}}},
+	{`(?<n>a)(?<n>b)\k<n>`, []Incompatibility{{0, 7, "duplicate group name <n>"}, {7, 14, "duplicate group name <n>"}, {14, 19, "backreference"}},
+		[]Incompatibility{{0, 7, "duplicate group name <n>"}, {7, 14, "duplicate group name <n>"}, {14, 19, "backreference to duplicate name <n>"}}},
+	{`(a)(?i:\1)`, []Incompatibility{{7, 9, "backreference"}}, []Incompatibility{{7, 9, "case-insensitive backreference"}}},
+	{`a\Kb`, []Incompatibility{{1, 3, `\K`}}, []Incompatibility{{1, 3, `\K`}}},
+}
+
+func TestConversionErrors(t *testing.T) {
+	for _, tc := range conversionErrorTests {
+		_, err := ToRE2(tc.pattern, ONIG_OPTION_NONE)
+		checkIncompatibilities(t, "ToRE2", tc.pattern, err, tc.re2)
+		_, _, err = ToJavaScript(tc.pattern, ONIG_OPTION_NONE)
+		checkIncompatibilities(t, "ToJavaScript", tc.pattern, err, tc.js)
+	}
+	if _, err := ToRE2(`a`, ONIG_OPTION_FIND_LONGEST); err == nil {
+		t.Errorf("expected an error for ONIG_OPTION_FIND_LONGEST")
+	}
+	if _, err := ToRE2(`(a`, ONIG_OPTION_NONE); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+	// builds without cgo do not compile Perl syntax at all
+	if re, err := CompileWithSyntax(`a`, ONIG_OPTION_NONE, ONIG_SYNTAX_PERL); err == nil {
+		if _, err := re.ToRE2(); err == nil {
+			t.Errorf("expected an error for a Perl-syntax pattern")
+		}
+	}
+}
+
+func checkIncompatibilities(t *testing.T, name, pattern string, err error, expected []Incompatibility) {
+	if expected == nil {
+		if err != nil {
+			t.Errorf("%s(%q): unexpected error %v", name, pattern, err)
+		}
+		return
+	}
+	cerr, ok := err.(*ConversionError)
+	if !ok {
+		t.Errorf("%s(%q) = %v; want a conversion error", name, pattern, err)
+		return
+	}
+	if !reflect.DeepEqual(cerr.Incompatibilities, expected) {
+		t.Errorf("%s(%q) reported %v; want %v", name, pattern, cerr.Incompatibilities, expected)
+	}
+}
+
+// Converted patterns match the same text in Go's regexp as in rubex.
+func TestRE2ConversionMatches(t *testing.T) {
+	patterns := []string{`\w+`, `\d+`, `\s+`, `[^\w\s]+`, `^\w`, `\w$`, `(?i)stra`, `[[:upper:]][[:lower:]]+`, `\p{Greek}+`, `a.c`, `(?m)a.c`, `(x)?(?<n>y)`, `[a-z&&[^aeiou]]+`, `\h+`}
+	inputs := []string{"", "abc", "a\nc", "Straße STRASSE", "été αβγ ١٢٣", "Hello World\nbye", "xy y", "deadBEEF g", "a_b  \u00a0\u2028c"}
+	for _, pattern := range patterns {
+		converted, err := ToRE2(pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			t.Errorf("ToRE2(%q): unexpected error: %v", pattern, err)
+			continue
+		}
+		std := regexp.MustCompile(converted)
+		re := MustCompile(pattern)
+		for _, input := range inputs {
+			if actual, expected := std.FindAllStringSubmatchIndex(input, -1), re.FindAllStringSubmatchIndex(input, -1); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%q (%q) on %q: regexp found %v, rubex %v", pattern, converted, input, actual, expected)
+			}
+		}
+	}
+}
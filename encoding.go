@@ -0,0 +1,130 @@
+package rubex
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a pattern and of the text it searches.
+// Match positions are always byte offsets into the searched buffer.
+//
+// Replacement templates ("\\1", "\\k<name>", "$1") are read in the encoding
+// of the Regexp, so with UTF-16 and UTF-32 they are written in that encoding
+// too. The Reader methods decode runes and assume UTF-8.
+type Encoding int
+
+// The supported Oniguruma encodings. The order matches the encoding table in
+// chelper.c.
+const (
+	ONIG_ENCODING_UTF8 Encoding = iota
+	ONIG_ENCODING_ASCII
+	ONIG_ENCODING_UTF16_BE
+	ONIG_ENCODING_UTF16_LE
+	ONIG_ENCODING_UTF32_BE
+	ONIG_ENCODING_UTF32_LE
+	ONIG_ENCODING_ISO_8859_1
+	ONIG_ENCODING_ISO_8859_2
+	ONIG_ENCODING_ISO_8859_3
+	ONIG_ENCODING_ISO_8859_4
+	ONIG_ENCODING_ISO_8859_5
+	ONIG_ENCODING_ISO_8859_6
+	ONIG_ENCODING_ISO_8859_7
+	ONIG_ENCODING_ISO_8859_8
+	ONIG_ENCODING_ISO_8859_9
+	ONIG_ENCODING_ISO_8859_10
+	ONIG_ENCODING_ISO_8859_11
+	ONIG_ENCODING_ISO_8859_13
+	ONIG_ENCODING_ISO_8859_14
+	ONIG_ENCODING_ISO_8859_15
+	ONIG_ENCODING_ISO_8859_16
+	ONIG_ENCODING_EUC_JP
+	ONIG_ENCODING_EUC_TW
+	ONIG_ENCODING_EUC_KR
+	ONIG_ENCODING_EUC_CN
+	ONIG_ENCODING_SJIS
+	ONIG_ENCODING_KOI8_R
+	ONIG_ENCODING_CP1251
+	ONIG_ENCODING_BIG5
+	ONIG_ENCODING_GB18030
+
+	// ONIG_ENCODING_BINARY treats text as plain bytes, like Ruby's ASCII-8BIT.
+	// Bytes above 0x7f are valid characters with no character class. Oniguruma
+	// searches it with its ASCII encoding, so it has no entry in chelper.c.
+	ONIG_ENCODING_BINARY
+
+	ONIG_ENCODING_DEFAULT = ONIG_ENCODING_UTF8
+)
+
+var encodingNames = []string{
+	ONIG_ENCODING_UTF8:        "UTF-8",
+	ONIG_ENCODING_ASCII:       "ASCII",
+	ONIG_ENCODING_UTF16_BE:    "UTF-16BE",
+	ONIG_ENCODING_UTF16_LE:    "UTF-16LE",
+	ONIG_ENCODING_UTF32_BE:    "UTF-32BE",
+	ONIG_ENCODING_UTF32_LE:    "UTF-32LE",
+	ONIG_ENCODING_ISO_8859_1:  "ISO-8859-1",
+	ONIG_ENCODING_ISO_8859_2:  "ISO-8859-2",
+	ONIG_ENCODING_ISO_8859_3:  "ISO-8859-3",
+	ONIG_ENCODING_ISO_8859_4:  "ISO-8859-4",
+	ONIG_ENCODING_ISO_8859_5:  "ISO-8859-5",
+	ONIG_ENCODING_ISO_8859_6:  "ISO-8859-6",
+	ONIG_ENCODING_ISO_8859_7:  "ISO-8859-7",
+	ONIG_ENCODING_ISO_8859_8:  "ISO-8859-8",
+	ONIG_ENCODING_ISO_8859_9:  "ISO-8859-9",
+	ONIG_ENCODING_ISO_8859_10: "ISO-8859-10",
+	ONIG_ENCODING_ISO_8859_11: "ISO-8859-11",
+	ONIG_ENCODING_ISO_8859_13: "ISO-8859-13",
+	ONIG_ENCODING_ISO_8859_14: "ISO-8859-14",
+	ONIG_ENCODING_ISO_8859_15: "ISO-8859-15",
+	ONIG_ENCODING_ISO_8859_16: "ISO-8859-16",
+	ONIG_ENCODING_EUC_JP:      "EUC-JP",
+	ONIG_ENCODING_EUC_TW:      "EUC-TW",
+	ONIG_ENCODING_EUC_KR:      "EUC-KR",
+	ONIG_ENCODING_EUC_CN:      "EUC-CN",
+	ONIG_ENCODING_SJIS:        "Shift_JIS",
+	ONIG_ENCODING_KOI8_R:      "KOI8-R",
+	ONIG_ENCODING_CP1251:      "CP1251",
+	ONIG_ENCODING_BIG5:        "Big5",
+	ONIG_ENCODING_GB18030:     "GB18030",
+	ONIG_ENCODING_BINARY:      "ASCII-8BIT",
+}
+
+func (encoding Encoding) String() string {
+	if encoding >= 0 && int(encoding) < len(encodingNames) {
+		return encodingNames[encoding]
+	}
+	return "Encoding(" + strconv.Itoa(int(encoding)) + ")"
+}
+
+// Encoding returns the encoding the pattern was compiled for.
+func (re *Regexp) Encoding() Encoding {
+	return re.encoding
+}
+
+// codeUnit returns the size in bytes of the code units of the encoding and
+// whether they are big-endian.
+func (encoding Encoding) codeUnit() (size int, bigEndian bool) {
+	switch encoding {
+	case ONIG_ENCODING_UTF16_BE:
+		return 2, true
+	case ONIG_ENCODING_UTF16_LE:
+		return 2, false
+	case ONIG_ENCODING_UTF32_BE:
+		return 4, true
+	case ONIG_ENCODING_UTF32_LE:
+		return 4, false
+	}
+	return 1, false
+}
+
+// charLength returns the width in bytes of the character at b[offset:n].
+func (re *Regexp) charLength(b []byte, n int, offset int) int {
+	switch re.encoding {
+	case ONIG_ENCODING_UTF8:
+		_, width := utf8.DecodeRune(b[offset:n])
+		return width
+	case ONIG_ENCODING_BINARY:
+		return 1
+	}
+	return re.nativeCharLength(b, n, offset)
+}
@@ -0,0 +1,24 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+/*
+#include <oniguruma.h>
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+func (encoding Encoding) onigEncoding() C.OnigEncoding {
+	if encoding == ONIG_ENCODING_BINARY {
+		encoding = ONIG_ENCODING_ASCII
+	}
+	return C.GetOnigEncoding(C.int(encoding))
+}
+
+func (re *Regexp) nativeCharLength(b []byte, n int, offset int) int {
+	return int(C.OnigCharLength(re.encoding.onigEncoding(), unsafe.Pointer(&b[0]), C.int(n), C.int(offset)))
+}
@@ -0,0 +1,135 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The same text in the encodings under test.
+const (
+	nihonSJIS    = "\x93\xfa\x96\x7b"                 // 日本
+	nihonEUCJP   = "\xc6\xfc\xcb\xdc"                 // 日本
+	nihonUTF16LE = "\xe5\x65\x2c\x67"                 // 日本
+	nihonUTF16BE = "\x65\xe5\x67\x2c"                 // 日本
+	nihonUTF32BE = "\x00\x00\x65\xe5\x00\x00\x67\x2c" // 日本
+)
+
+type encodingTest struct {
+	encoding Encoding
+	pattern  string
+	text     string
+	matches  [][]int
+}
+
+var encodingTests = []encodingTest{
+	// empty matches advance by one character, never into the middle of one
+	{ONIG_ENCODING_SJIS, "a*", nihonSJIS, build(3, 0, 0, 2, 2, 4, 4)},
+	{ONIG_ENCODING_EUC_JP, "a*", nihonEUCJP, build(3, 0, 0, 2, 2, 4, 4)},
+	{ONIG_ENCODING_UTF16_LE, "a\x00*\x00", nihonUTF16LE, build(3, 0, 0, 2, 2, 4, 4)},
+	{ONIG_ENCODING_UTF32_BE, "\x00\x00\x00a\x00\x00\x00*", nihonUTF32BE, build(3, 0, 0, 4, 4, 8, 8)},
+	{ONIG_ENCODING_ISO_8859_1, "x*", "caf\xe9", build(5, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4)},
+
+	// . matches whole characters
+	{ONIG_ENCODING_SJIS, ".", "a" + nihonSJIS, build(3, 0, 1, 1, 3, 3, 5)},
+	{ONIG_ENCODING_EUC_JP, ".", nihonEUCJP, build(2, 0, 2, 2, 4)},
+	{ONIG_ENCODING_UTF16_BE, "\x00.", nihonUTF16BE, build(2, 0, 2, 2, 4)},
+
+	// a trail byte of a double byte character is never a match on its own
+	{ONIG_ENCODING_SJIS, "\x7b", nihonSJIS + "\x7b", build(1, 4, 5)},
+
+	// character classes follow the encoding
+	{ONIG_ENCODING_ISO_8859_1, `\w+`, " caf\xe9 ", build(1, 1, 5)},
+	{ONIG_ENCODING_ISO_8859_5, `\w+`, "\xbf\xe0\xd8", build(1, 0, 3)},
+}
+
+func TestFindAllIndexWithEncoding(t *testing.T) {
+	for _, tc := range encodingTests {
+		re, err := CompileWithEncoding(tc.pattern, ONIG_OPTION_DEFAULT, tc.encoding)
+		if err != nil {
+			t.Errorf("%s: unexpected error compiling %q: %v", tc.encoding, tc.pattern, err)
+			continue
+		}
+		if re.Encoding() != tc.encoding {
+			t.Errorf("Encoding() = %s; want %s", re.Encoding(), tc.encoding)
+		}
+		actual := re.FindAllIndex([]byte(tc.text), -1)
+		if !reflect.DeepEqual(actual, tc.matches) {
+			t.Errorf("%s: %q.FindAllIndex(%q) = %v; want %v", tc.encoding, tc.pattern, tc.text, actual, tc.matches)
+		}
+	}
+}
+
+func TestReplaceWithEncoding(t *testing.T) {
+	re := MustCompileWithEncoding("", ONIG_OPTION_DEFAULT, ONIG_ENCODING_SJIS)
+	if actual := re.ReplaceAllString(nihonSJIS, "-"); actual != "-\x93\xfa-\x96\x7b-" {
+		t.Errorf("ReplaceAllString = %q", actual)
+	}
+	re = MustCompileWithEncoding("(\x96\x7b)", ONIG_OPTION_DEFAULT, ONIG_ENCODING_SJIS)
+	if actual := re.Gsub(nihonSJIS, "<\\1>"); actual != "\x93\xfa<\x96\x7b>" {
+		t.Errorf("Gsub = %q", actual)
+	}
+	re = MustCompileWithEncoding("\x00[\x00a\x00b\x00]", ONIG_OPTION_DEFAULT, ONIG_ENCODING_UTF16_BE)
+	if actual := re.ReplaceAllStringFunc("\x00a\x00b", func(s string) string { return "\x00" + s }); actual != "\x00\x00a\x00\x00b" {
+		t.Errorf("ReplaceAllStringFunc = %q", actual)
+	}
+}
+
+// wide encodes the ASCII text s in UTF-16 or UTF-32.
+func wide(s string, size int, bigEndian bool) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		unit := make([]byte, size)
+		if bigEndian {
+			unit[size-1] = s[i]
+		} else {
+			unit[0] = s[i]
+		}
+		b = append(b, unit...)
+	}
+	return string(b)
+}
+
+func TestTemplateWithWideEncoding(t *testing.T) {
+	for _, enc := range []Encoding{ONIG_ENCODING_UTF16_LE, ONIG_ENCODING_UTF16_BE, ONIG_ENCODING_UTF32_LE, ONIG_ENCODING_UTF32_BE} {
+		size, bigEndian := enc.codeUnit()
+		w := func(s string) string { return wide(s, size, bigEndian) }
+		re := MustCompileWithEncoding(w("(b)(c)"), ONIG_OPTION_DEFAULT, enc)
+		if actual := re.Gsub(w("abcd"), w(`<\2\1\\\z>`)); actual != w(`a<cb\\z>d`) {
+			t.Errorf("%s: Gsub = %q", enc, actual)
+		}
+		if actual := re.ReplaceAllString(w("abcd"), w(`\2`)+nihonUTF16LE[:size]); actual != w("ac")+nihonUTF16LE[:size]+w("d") {
+			t.Errorf("%s: ReplaceAllString = %q", enc, actual)
+		}
+		src := w("abcd")
+		match := re.FindStringSubmatchIndex(src)
+		if actual := string(re.ExpandString(nil, w("$2${1}$$$x"), src, match)); actual != w("cb$") {
+			t.Errorf("%s: ExpandString = %q", enc, actual)
+		}
+		if actual := string(re.RubyExpandString(nil, w(`\0\'\\\q\`), src, match)); actual != w(`bcd\\q\`) {
+			t.Errorf("%s: RubyExpandString = %q", enc, actual)
+		}
+		re = MustCompileWithEncoding(w("(?<x>b)"), ONIG_OPTION_DEFAULT, enc)
+		if actual := re.Gsub(w("abc"), w(`[\k<x>]`)); actual != w("a[b]c") {
+			t.Errorf("%s: Gsub with a name = %q", enc, actual)
+		}
+		src = w("abc")
+		match = re.FindStringSubmatchIndex(src)
+		if actual := string(re.ExpandString(nil, w("${x}-$x"), src, match)); actual != w("b-b") {
+			t.Errorf("%s: ExpandString with a name = %q", enc, actual)
+		}
+		if actual := string(re.RubyExpandString(nil, w(`\k<x>\k<x`), src, match)); actual != w(`b\k<x`) {
+			t.Errorf("%s: RubyExpandString with a name = %q", enc, actual)
+		}
+	}
+}
+
+func TestCompileWithUnknownEncoding(t *testing.T) {
+	if _, err := CompileWithEncoding("a", ONIG_OPTION_DEFAULT, Encoding(1000)); err == nil {
+		t.Errorf("expected an error for an unknown encoding")
+	}
+	if s := ONIG_ENCODING_SJIS.String(); s != "Shift_JIS" {
+		t.Errorf("String() = %q", s)
+	}
+}
@@ -0,0 +1,295 @@
+package rubex
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expand appends template to dst and returns the result; during the append,
+// Expand replaces variables in the template with corresponding matches drawn
+// from src. The match slice should have been returned by FindSubmatchIndex.
+//
+// The template uses the syntax of Go's regexp package: $name or ${name}
+// refers to a group by number or by name, the longest run of letters, digits
+// and underscores after the $ making up the name, and $$ is a literal $. A
+// reference to a group that is out of range or did not participate in the
+// match is replaced with nothing. When several groups share a name, the first
+// that participated is used. RubyExpand takes Ruby's syntax instead.
+func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
+	return re.expand(dst, string(template), src, "", match)
+}
+
+// ExpandString is like Expand but the template and source are strings. It
+// appends to and returns a byte slice in order to give the calling code
+// control over allocation.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	return re.expand(dst, template, nil, src, match)
+}
+
+// RubyExpand is Expand with the template syntax of Ruby's String#sub:
+//
+//	\1 to \9    the group with that number
+//	\k<name>    the named group; of several with the name, the last that participated
+//	\0 or \&    the whole match
+//	\`          the text before the match
+//	\'          the text after the match
+//	\+          the last group that participated
+//	\\          a backslash
+//
+// Other backslashes are copied as they are. As in Ruby, \1 to \9 are replaced
+// with nothing when the pattern has named groups, unless it was compiled with
+// ONIG_OPTION_CAPTURE_GROUP. Where Ruby raises an error, a \k<name> with an
+// unknown name is replaced with nothing and one without the closing > is
+// copied.
+func (re *Regexp) RubyExpand(dst []byte, template []byte, src []byte, match []int) []byte {
+	return re.rubyExpand(dst, string(template), src, "", match)
+}
+
+// RubyExpandString is RubyExpand for a string template and source.
+func (re *Regexp) RubyExpandString(dst []byte, template string, src string, match []int) []byte {
+	return re.rubyExpand(dst, template, nil, src, match)
+}
+
+// appendGroup appends the text of group i, if it took part in the match, from
+// bsrc or, when bsrc is nil, from src.
+func appendGroup(dst []byte, bsrc []byte, src string, match []int, i int) []byte {
+	if i < 0 || 2*i+1 >= len(match) || match[2*i] < 0 {
+		return dst
+	}
+	if bsrc != nil {
+		return append(dst, bsrc[match[2*i]:match[2*i+1]]...)
+	}
+	return append(dst, src[match[2*i]:match[2*i+1]]...)
+}
+
+// templateText is a replacement template read by code unit in the encoding of
+// its Regexp: a byte for the ASCII-compatible encodings, two or four bytes for
+// UTF-16 and UTF-32. The ASCII characters of the template syntax are code
+// units with their ASCII values in every encoding.
+type templateText struct {
+	text      string
+	size      int
+	bigEndian bool
+}
+
+func (re *Regexp) templateText(text string) templateText {
+	size, bigEndian := re.encoding.codeUnit()
+	return templateText{text, size, bigEndian}
+}
+
+// at returns the code unit at byte i, or -1 when the text ends before it does.
+func (t templateText) at(i int) rune {
+	if i+t.size > len(t.text) {
+		return -1
+	}
+	u := t.text[i : i+t.size]
+	var c uint32
+	for j := 0; j < t.size; j++ {
+		if t.bigEndian {
+			c = c<<8 | uint32(u[j])
+		} else {
+			c = c<<8 | uint32(u[t.size-1-j])
+		}
+	}
+	if c > utf8.MaxRune {
+		return utf8.RuneError
+	}
+	return rune(c)
+}
+
+// unit returns the bytes of the code unit at byte i.
+func (t templateText) unit(i int) string {
+	if i+t.size > len(t.text) {
+		return t.text[i:]
+	}
+	return t.text[i : i+t.size]
+}
+
+// decode returns the character at byte i and its width in bytes. Byte-wide
+// text is decoded as UTF-8.
+func (t templateText) decode(i int) (r rune, width int) {
+	if t.size == 1 {
+		return utf8.DecodeRuneInString(t.text[i:])
+	}
+	if r = t.at(i); r < 0 {
+		return utf8.RuneError, len(t.text) - i
+	}
+	return r, t.size
+}
+
+// index returns the offset of the first code unit c at or after byte from, or
+// the length of the text if there is none.
+func (t templateText) index(from int, c rune) int {
+	for i := from; i < len(t.text); i += t.size {
+		if t.at(i) == c {
+			return i
+		}
+	}
+	return len(t.text)
+}
+
+// appendASCII appends the ASCII characters cs encoded as the template is.
+func (t templateText) appendASCII(dst []byte, cs ...byte) []byte {
+	for _, c := range cs {
+		unit := make([]byte, t.size)
+		if t.bigEndian {
+			unit[t.size-1] = c
+		} else {
+			unit[0] = c
+		}
+		dst = append(dst, unit...)
+	}
+	return dst
+}
+
+func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, match []int) []byte {
+	t := re.templateText(template)
+	for len(t.text) > 0 {
+		i := t.index(0, '$')
+		dst = append(dst, t.text[:i]...)
+		if i == len(t.text) {
+			break
+		}
+		t.text = t.text[i+t.size:]
+		if t.at(0) == '$' {
+			//$$ is a literal $
+			dst = append(dst, t.unit(0)...)
+			t.text = t.text[t.size:]
+			continue
+		}
+		name, num, rest, ok := t.goName()
+		if !ok {
+			//malformed, treat $ as raw text
+			dst = t.appendASCII(dst, '$')
+			continue
+		}
+		t.text = rest
+		if num >= 0 {
+			dst = appendGroup(dst, bsrc, src, match, num)
+			continue
+		}
+		for _, index := range re.SubexpIndices(name) {
+			if 2*index+1 < len(match) && match[2*index] >= 0 {
+				dst = appendGroup(dst, bsrc, src, match, index)
+				break
+			}
+		}
+	}
+	return dst
+}
+
+// goName returns the name from a leading "name" or "{name}" in the template
+// and the text after it. num is the group number when the name is a decimal
+// number without leading zeros, -1 otherwise.
+func (t templateText) goName() (name string, num int, rest string, ok bool) {
+	if t.text == "" {
+		return
+	}
+	start := 0
+	brace := false
+	if t.at(0) == '{' {
+		brace = true
+		start = t.size
+	}
+	i := start
+	for i < len(t.text) {
+		r, width := t.decode(i)
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += width
+	}
+	if i == start {
+		//an empty name is not ok
+		return
+	}
+	name = t.text[start:i]
+	end := i
+	if brace {
+		if t.at(i) != '}' {
+			//missing closing brace
+			return
+		}
+		i += t.size
+	}
+	num = 0
+	for j := start; j < end; j += t.size {
+		d := t.at(j)
+		if d < '0' || '9' < d || num >= 1e8 {
+			num = -1
+			break
+		}
+		num = num*10 + int(d) - '0'
+	}
+	if t.at(start) == '0' && end-start > t.size {
+		num = -1
+	}
+	rest = t.text[i:]
+	ok = true
+	return
+}
+
+func (re *Regexp) rubyExpand(dst []byte, template string, bsrc []byte, src string, match []int) []byte {
+	if len(match) < 2 {
+		return append(dst, template...)
+	}
+	srcLen := len(src)
+	if bsrc != nil {
+		srcLen = len(bsrc)
+	}
+	//without ONIG_OPTION_CAPTURE_GROUP, unnamed groups are not captured next to named ones
+	numbered := re.namedGroupInfo == nil || re.option&ONIG_OPTION_CAPTURE_GROUP != 0
+	t := re.templateText(template)
+	for len(t.text) > 0 {
+		i := t.index(0, '\\')
+		dst = append(dst, t.text[:i]...)
+		if i+2*t.size > len(t.text) {
+			//no backslash, or a trailing one
+			dst = append(dst, t.text[i:]...)
+			break
+		}
+		c := t.at(i + t.size)
+		escape := t.text[i : i+2*t.size]
+		t.text = t.text[i+2*t.size:]
+		switch {
+		case '1' <= c && c <= '9':
+			if numbered {
+				dst = appendGroup(dst, bsrc, src, match, int(c-'0'))
+			}
+		case c == '0' || c == '&':
+			dst = appendGroup(dst, bsrc, src, match, 0)
+		case c == '`':
+			dst = appendGroup(dst, bsrc, src, []int{0, match[0]}, 0)
+		case c == '\'':
+			dst = appendGroup(dst, bsrc, src, []int{match[1], srcLen}, 0)
+		case c == '+':
+			last := len(match)/2 - 1
+			for last > 0 && match[2*last] < 0 {
+				last--
+			}
+			if last > 0 {
+				dst = appendGroup(dst, bsrc, src, match, last)
+			}
+		case c == '\\':
+			dst = append(dst, escape[t.size:]...)
+		case c == 'k' && t.at(0) == '<':
+			end := t.index(t.size, '>')
+			if end == len(t.text) {
+				//no closing >
+				dst = append(dst, escape...)
+				continue
+			}
+			indices := re.SubexpIndices(t.text[t.size:end])
+			t.text = t.text[end+t.size:]
+			for j := len(indices) - 1; j >= 0; j-- {
+				if 2*indices[j]+1 < len(match) && match[2*indices[j]] >= 0 {
+					dst = appendGroup(dst, bsrc, src, match, indices[j])
+					break
+				}
+			}
+		default:
+			dst = append(dst, escape...)
+		}
+	}
+	return dst
+}
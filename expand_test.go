@@ -0,0 +1,94 @@
+package rubex
+
+import (
+	"regexp"
+	"testing"
+)
+
+var expandTemplates = []string{
+	"",
+	"plain",
+	"$1-$2",
+	"${1}x",
+	"$1x",
+	"$first $last",
+	"${first}_${last}",
+	"$$1",
+	"$",
+	"$!",
+	"${first",
+	"${}",
+	"$01",
+	"$3 $10",
+	"$0",
+	"é$2é",
+	"$missing.",
+}
+
+func TestExpand(t *testing.T) {
+	patterns := [][2]string{
+		{`(?<first>\w+) (?<last>\w+)`, `(?P<first>\w+) (?P<last>\w+)`},
+		{`(\w)(x)?`, `(\w)(x)?`},
+	}
+	for _, pair := range patterns {
+		re, goRe := MustCompile(pair[0]), regexp.MustCompile(pair[1])
+		src := "ab cd"
+		match := re.FindStringSubmatchIndex(src)
+		for _, template := range expandTemplates {
+			expected := string(goRe.ExpandString([]byte("> "), template, src, match))
+			if actual := string(re.ExpandString([]byte("> "), template, src, match)); actual != expected {
+				t.Errorf("%q.ExpandString(%q) = %q; want %q", pair[0], template, actual, expected)
+			}
+			if actual := string(re.Expand([]byte("> "), []byte(template), []byte(src), match)); actual != expected {
+				t.Errorf("%q.Expand(%q) = %q; want %q", pair[0], template, actual, expected)
+			}
+		}
+	}
+}
+
+func TestExpandDuplicateNames(t *testing.T) {
+	re := MustCompile(`(?<x>a)|(?<x>b)`)
+	src := "b"
+	match := re.FindStringSubmatchIndex(src)
+	if actual := string(re.ExpandString(nil, "[$x]", src, match)); actual != "[b]" {
+		t.Errorf("ExpandString = %q; want %q", actual, "[b]")
+	}
+	if actual := string(re.RubyExpandString(nil, `[\k<x>]`, src, match)); actual != "[b]" {
+		t.Errorf("RubyExpandString = %q; want %q", actual, "[b]")
+	}
+}
+
+// Results of String#sub in Ruby, which raises on an unknown or unterminated
+// \k<name> instead.
+var rubyExpandTests = []struct {
+	pattern  string
+	option   Option
+	src      string
+	template string
+	expected string
+}{
+	{`(\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", `\2 at \1`, "y at x"},
+	{`(\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", `[\0|\&]`, "[x@y|x@y]"},
+	{`(\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", "<\\`|\\'>", "<to |.com>"},
+	{`(\w+)@(\w+)(z)?`, ONIG_OPTION_NONE, "to x@y.com", `\+\3\9`, "y"},
+	{`(\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", `a\\1 \x \`, `a\1 \x \`},
+	{`(?<user>\w+)@(?<host>\w+)`, ONIG_OPTION_NONE, "to x@y.com", `\k<host>/\k<user>`, "y/x"},
+	{`(?<user>\w+)@(?<host>\w+)`, ONIG_OPTION_NONE, "to x@y.com", `\1\k<nope>\k<host`, `\k<host`},
+	{`(?<user>\w+)@(\w+)`, ONIG_OPTION_CAPTURE_GROUP, "to x@y.com", `\2.\1`, "y.x"},
+	{`(?<user>\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", `\k<user>\2`, "x"},
+	{`(?<user>\w+)@(\w+)`, ONIG_OPTION_NONE, "to x@y.com", `\kuser`, `\kuser`},
+	{`(é)@(\w+)`, ONIG_OPTION_NONE, "é@y", `\1\é`, `é\é`},
+}
+
+func TestRubyExpand(t *testing.T) {
+	for _, test := range rubyExpandTests {
+		re := MustCompileWithOption(test.pattern, test.option)
+		match := re.FindStringSubmatchIndex(test.src)
+		if actual := string(re.RubyExpandString(nil, test.template, test.src, match)); actual != test.expected {
+			t.Errorf("%q.RubyExpandString(%q) = %q; want %q", test.pattern, test.template, actual, test.expected)
+		}
+		if actual := string(re.RubyExpand(nil, []byte(test.template), []byte(test.src), match)); actual != test.expected {
+			t.Errorf("%q.RubyExpand(%q) = %q; want %q", test.pattern, test.template, actual, test.expected)
+		}
+	}
+}
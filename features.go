@@ -0,0 +1,119 @@
+package rubex
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version returns the version of the Oniguruma library rubex is linked
+// against, such as "6.9.8", or "" in builds without cgo.
+func Version() string {
+	return libraryVersion()
+}
+
+// A FeatureSet describes what the regex library in use can do, so programs
+// can refuse to start, or fall back, when it lacks something they need.
+type FeatureSet struct {
+	// Oniguruma is false in builds without cgo, which run the pure-Go
+	// backtracker instead.
+	Oniguruma bool
+	// Version is the Oniguruma version, split into its parts in Major, Minor
+	// and Teeny.
+	Version             string
+	Major, Minor, Teeny int
+
+	// Syntaxes and Encodings are those patterns can be compiled with.
+	Syntaxes  []Syntax
+	Encodings []Encoding
+
+	// AbsentOperator is (?~...).
+	AbsentOperator bool
+	// Keep is \K.
+	Keep bool
+	// GeneralNewline is \R.
+	GeneralNewline bool
+	// TextSegments are \X, \y and \Y.
+	TextSegments bool
+	// SubexpCalls are \g<name> and \g<n>.
+	SubexpCalls bool
+	// FindLongest reports whether searching with ONIG_OPTION_FIND_LONGEST
+	// finds the longest match; some Oniguruma releases only compare the first
+	// match at each position. Longest does not depend on it.
+	FindLongest bool
+}
+
+// AtLeast reports whether the Oniguruma version is major.minor.teeny or later.
+// It is false in builds without cgo.
+func (f FeatureSet) AtLeast(major, minor, teeny int) bool {
+	if !f.Oniguruma {
+		return false
+	}
+	if f.Major != major {
+		return f.Major > major
+	}
+	if f.Minor != minor {
+		return f.Minor > minor
+	}
+	return f.Teeny >= teeny
+}
+
+var (
+	features     FeatureSet
+	featuresOnce sync.Once
+)
+
+// Features reports what the regex library in use supports. Capabilities are
+// found by compiling a pattern that needs each one, the first time Features is
+// called.
+func Features() FeatureSet {
+	featuresOnce.Do(func() {
+		features = probeFeatures()
+	})
+	f := features
+	f.Syntaxes = append([]Syntax(nil), features.Syntaxes...)
+	f.Encodings = append([]Encoding(nil), features.Encodings...)
+	return f
+}
+
+func probeFeatures() FeatureSet {
+	f := FeatureSet{Version: libraryVersion()}
+	if f.Version != "" {
+		f.Oniguruma = true
+		parts := strings.SplitN(f.Version, ".", 3)
+		numbers := []*int{&f.Major, &f.Minor, &f.Teeny}
+		for i, part := range parts {
+			*numbers[i], _ = strconv.Atoi(part)
+		}
+	}
+	for syntax := ONIG_SYNTAX_RUBY; int(syntax) < len(syntaxNames); syntax++ {
+		if compiles(``, ONIG_OPTION_NONE, syntax, ONIG_ENCODING_DEFAULT) {
+			f.Syntaxes = append(f.Syntaxes, syntax)
+		}
+	}
+	for encoding := ONIG_ENCODING_UTF8; int(encoding) < len(encodingNames); encoding++ {
+		if compiles(``, ONIG_OPTION_NONE, ONIG_SYNTAX_DEFAULT, encoding) {
+			f.Encodings = append(f.Encodings, encoding)
+		}
+	}
+	f.AbsentOperator = compiles(`(?~a)`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	f.Keep = compiles(`a\Kb`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	f.GeneralNewline = compiles(`\R`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	f.TextSegments = compiles(`\X\y\Y`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	f.SubexpCalls = compiles(`(?<a>a)\g<a>`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8)
+	if re, err := NewRegexpWithEncoding(`a|ab`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8); err == nil {
+		loc := re.find([]byte("ab"), 2, 0, ONIG_OPTION_FIND_LONGEST)
+		f.FindLongest = len(loc) >= 2 && loc[1] == 2
+		re.Free()
+	}
+	return f
+}
+
+func compiles(pattern string, option Option, syntax Syntax, encoding Encoding) bool {
+	re, err := NewRegexpWithEncoding(pattern, option, syntax, encoding)
+	if err != nil {
+		return false
+	}
+	re.Free()
+	return true
+}
@@ -0,0 +1,54 @@
+package rubex
+
+import (
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	f := Features()
+	if f.Version != Version() {
+		t.Errorf("Features().Version = %q; Version() = %q", f.Version, Version())
+	}
+	if f.Oniguruma != (Version() != "") {
+		t.Errorf("Features().Oniguruma = %v with version %q", f.Oniguruma, Version())
+	}
+	if len(f.Syntaxes) == 0 || f.Syntaxes[0] != ONIG_SYNTAX_RUBY {
+		t.Errorf("Features().Syntaxes = %v; want ONIG_SYNTAX_RUBY first", f.Syntaxes)
+	}
+	if len(f.Encodings) == 0 || f.Encodings[0] != ONIG_ENCODING_UTF8 {
+		t.Errorf("Features().Encodings = %v; want UTF-8 first", f.Encodings)
+	}
+	if !f.SubexpCalls {
+		t.Errorf("Features().SubexpCalls = false")
+	}
+	if _, err := Compile(`a\Kb`); (err == nil) != f.Keep {
+		t.Errorf("Features().Keep = %v but compiling \\K gave %v", f.Keep, err)
+	}
+	f.Syntaxes[0] = ONIG_SYNTAX_PERL
+	if Features().Syntaxes[0] != ONIG_SYNTAX_RUBY {
+		t.Errorf("changing the result of Features() changed the next one")
+	}
+}
+
+func TestFeatureSetAtLeast(t *testing.T) {
+	f := FeatureSet{Oniguruma: true, Version: "6.9.8", Major: 6, Minor: 9, Teeny: 8}
+	tests := []struct {
+		major, minor, teeny int
+		expected            bool
+	}{
+		{6, 9, 8, true},
+		{6, 9, 7, true},
+		{5, 10, 10, true},
+		{6, 9, 9, false},
+		{6, 10, 0, false},
+		{7, 0, 0, false},
+	}
+	for _, test := range tests {
+		if actual := f.AtLeast(test.major, test.minor, test.teeny); actual != test.expected {
+			t.Errorf("%s AtLeast(%d, %d, %d) = %v; want %v", f.Version, test.major, test.minor, test.teeny, actual, test.expected)
+		}
+	}
+	if (FeatureSet{}).AtLeast(0, 0, 0) {
+		t.Errorf("AtLeast is true without Oniguruma")
+	}
+}
@@ -0,0 +1,91 @@
+package rubex
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func expectFreedPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if r := recover(); r != ErrFreed {
+			t.Errorf("%s after Free: recovered %v; want ErrFreed", name, r)
+		}
+	}()
+	f()
+}
+
+func TestFreeIsIdempotent(t *testing.T) {
+	re := MustCompile("a+")
+	re.Free()
+	re.Free()
+	if err := re.Close(); err != ErrFreed {
+		t.Errorf("Close after Free = %v; want ErrFreed", err)
+	}
+}
+
+func TestCloseReportsSecondClose(t *testing.T) {
+	re := MustCompile("a+")
+	if err := re.Close(); err != nil {
+		t.Errorf("first Close = %v; want nil", err)
+	}
+	if err := re.Close(); err != ErrFreed {
+		t.Errorf("second Close = %v; want ErrFreed", err)
+	}
+}
+
+func TestSearchAfterFree(t *testing.T) {
+	re := MustCompile("a+")
+	re.Free()
+	expectFreedPanic(t, "MatchString", func() { re.MatchString("aaa") })
+	expectFreedPanic(t, "FindString", func() { re.FindString("aaa") })
+	expectFreedPanic(t, "FindAllString", func() { re.FindAllString("aaa", -1) })
+	expectFreedPanic(t, "Gsub", func() { re.Gsub("aaa", "b") })
+}
+
+func TestFreeDuringSearches(t *testing.T) {
+	re := MustCompile(`(\w+)\s`)
+	input := "the quick brown fox jumps over the lazy dog "
+	var wg sync.WaitGroup
+	start := make(chan bool)
+	for i := 0; i < numConcurrentRuns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil && r != ErrFreed {
+					t.Errorf("unexpected panic %v", r)
+				}
+			}()
+			<-start
+			for j := 0; j < numConcurrentSearches; j++ {
+				if n := len(re.FindAllString(input, -1)); n != 9 {
+					t.Errorf("FindAllString returned %d matches; want 9", n)
+				}
+			}
+		}()
+	}
+	close(start)
+	re.Free()
+	wg.Wait()
+	if atomic.LoadInt32(&re.refs) != 0 {
+		t.Errorf("%d references left after all searches finished", re.refs)
+	}
+}
+
+func TestFinalizerReleasesRegexps(t *testing.T) {
+	before := atomic.LoadInt64(&liveRegexps)
+	for i := 0; i < 100; i++ {
+		MustCompile("(a|b)*c").MatchString("ababc")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&liveRegexps) > before && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if live := atomic.LoadInt64(&liveRegexps); live > before {
+		t.Errorf("%d regexps still live after GC; want at most %d", live, before)
+	}
+}
@@ -0,0 +1,198 @@
+package rubex
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ASCII word characters, which is all Go's \b considers.
+const goWordChar = `[0-9A-Za-z_]`
+
+// CompileGo compiles a pattern written for Go's regexp package so that it
+// keeps the meaning it has there: ^ and $ anchor the whole text unless (?m)
+// is set, (?s) lets . match a newline, \b, \d, \s and \w are ASCII only, case
+// folding is simple folding, and (?P<name>...) defines a named group.
+// FindAll and ReplaceAll skip empty matches right after a previous match, as
+// Go's regexp does. Matching text that is not valid UTF-8 is not guaranteed to
+// agree with Go.
+//
+// String returns the translated pattern; see TranslateGo.
+func CompileGo(expr string) (*Regexp, error) {
+	pattern, option, err := TranslateGo(expr)
+	if err != nil {
+		return nil, err
+	}
+	re, err := NewRegexp(pattern, option)
+	if err != nil {
+		return nil, err
+	}
+	re.goEmptyMatches = true
+	return re, nil
+}
+
+func MustCompileGo(expr string) *Regexp {
+	regexp, error := CompileGo(expr)
+	if error != nil {
+		panic("regexp: compiling " + expr + ": " + error.Error())
+	}
+	return regexp
+}
+
+// TranslateGo parses a pattern in Go's regexp syntax and returns an
+// equivalent pattern in Ruby syntax, with the options to compile it with.
+// Parse errors are the ones regexp.Compile would report.
+func TranslateGo(expr string) (pattern string, option Option, err error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", ONIG_OPTION_NONE, err
+	}
+	var b strings.Builder
+	if err = writeGoRegexp(&b, re); err != nil {
+		return "", ONIG_OPTION_NONE, err
+	}
+	// unnamed groups are numbered even when the pattern has named groups
+	return b.String(), ONIG_OPTION_CAPTURE_GROUP, nil
+}
+
+func writeGoRegexp(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		b.WriteString(`(?!)`)
+	case syntax.OpEmptyMatch:
+		b.WriteString(`(?:)`)
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				writeGoFoldedRune(b, r)
+			} else {
+				writeGoRune(b, r)
+			}
+		}
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			b.WriteString(`(?!)`)
+			break
+		}
+		b.WriteByte('[')
+		for i := 0; i < len(re.Rune); i += 2 {
+			writeGoClassRange(b, re.Rune[i], re.Rune[i+1])
+		}
+		b.WriteByte(']')
+	case syntax.OpAnyCharNotNL:
+		b.WriteByte('.')
+	case syntax.OpAnyChar:
+		b.WriteString(`(?m:.)`)
+	case syntax.OpBeginLine:
+		// unlike Ruby's ^, Go's also matches after a newline that ends the text
+		b.WriteString(`(?:\A|(?<=\n))`)
+	case syntax.OpEndLine:
+		b.WriteString(`(?=\n|\z)`)
+	case syntax.OpBeginText:
+		b.WriteString(`\A`)
+	case syntax.OpEndText:
+		b.WriteString(`\z`)
+	case syntax.OpWordBoundary:
+		b.WriteString(`(?:(?<=` + goWordChar + `)(?!` + goWordChar + `)|(?<!` + goWordChar + `)(?=` + goWordChar + `))`)
+	case syntax.OpNoWordBoundary:
+		b.WriteString(`(?:(?<=` + goWordChar + `)(?=` + goWordChar + `)|(?<!` + goWordChar + `)(?!` + goWordChar + `))`)
+	case syntax.OpCapture:
+		if re.Name != "" {
+			if re.Name[0] >= '0' && re.Name[0] <= '9' {
+				return fmt.Errorf("rubex: group name %q cannot start with a digit", re.Name)
+			}
+			b.WriteString("(?<" + re.Name + ">")
+		} else {
+			b.WriteByte('(')
+		}
+		if err := writeGoRegexp(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		b.WriteString("(?:")
+		if err := writeGoRegexp(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+		switch re.Op {
+		case syntax.OpStar:
+			b.WriteByte('*')
+		case syntax.OpPlus:
+			b.WriteByte('+')
+		case syntax.OpQuest:
+			b.WriteByte('?')
+		case syntax.OpRepeat:
+			if re.Min == re.Max {
+				// Ruby reads {n}? as an optional {n}; a fixed count has nothing to be lazy about
+				b.WriteString("{" + strconv.Itoa(re.Min) + "}")
+				return nil
+			}
+			b.WriteString("{" + strconv.Itoa(re.Min) + ",")
+			if re.Max >= 0 {
+				b.WriteString(strconv.Itoa(re.Max))
+			}
+			b.WriteByte('}')
+		}
+		if re.Flags&syntax.NonGreedy != 0 {
+			b.WriteByte('?')
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := writeGoRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		b.WriteString("(?:")
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			if err := writeGoRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	default:
+		return fmt.Errorf("rubex: unsupported Go regexp operator %v", re.Op)
+	}
+	return nil
+}
+
+// writeGoRune writes r so that it always stands for itself.
+func writeGoRune(b *strings.Builder, r rune) {
+	if r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+		b.WriteRune(r)
+		return
+	}
+	b.WriteString(`\x{` + strconv.FormatInt(int64(r), 16) + `}`)
+}
+
+// writeGoFoldedRune writes a class of r and the runes it simple-folds to. Ruby's
+// (?i) would also apply multi-character folds such as ß to ss, which Go's
+// does not.
+func writeGoFoldedRune(b *strings.Builder, r rune) {
+	if unicode.SimpleFold(r) == r {
+		writeGoRune(b, r)
+		return
+	}
+	b.WriteByte('[')
+	for f := r; ; {
+		writeGoRune(b, f)
+		if f = unicode.SimpleFold(f); f == r {
+			break
+		}
+	}
+	b.WriteByte(']')
+}
+
+func writeGoClassRange(b *strings.Builder, lo, hi rune) {
+	writeGoRune(b, lo)
+	if hi != lo {
+		b.WriteByte('-')
+		writeGoRune(b, hi)
+	}
+}
@@ -0,0 +1,122 @@
+package rubex
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// Patterns whose meaning differs between Go and Ruby, or that Ruby syntax
+// does not accept at all.
+var goPatterns = []string{
+	`^a`,
+	`a$`,
+	`(?m)^a`,
+	`(?m)a$`,
+	`(?m)^$`,
+	`^$`,
+	`a.b`,
+	`(?s)a.b`,
+	`\Aa|b\z`,
+	`\bab\b`,
+	`\Bb\B`,
+	`\d+`,
+	`\w+`,
+	`\s+`,
+	`[^a]+`,
+	`[^\n]`,
+	`(?i)k`,
+	`(?i)ß`,
+	`(?i)[a-c]+`,
+	`(?P<first>a+)(b)?(?P<last>c*)`,
+	`(a)|(b)`,
+	`(a+?)(a*)`,
+	`(?U)(a+)(a*)`,
+	`a{2}`,
+	`a{2,}?`,
+	`a{1,2}`,
+	`(?:ab){0,2}c`,
+	`a*`,
+	`a*?`,
+	`|a`,
+	`x*|b`,
+	`[[:alpha:]]+`,
+	`\pL+`,
+	`\p{Greek}+`,
+	`[\x00-\x{10FFFF}]`,
+	`[^\x00-\x{10FFFF}]`,
+	`\.\+\*\?\(\)\|\[\]\{\}\^\$\\`,
+	`#\ #`,
+}
+
+var goInputs = []string{
+	"",
+	"a",
+	"ab",
+	"ba\n",
+	"a\nb\n",
+	"\n",
+	"a\nb",
+	"k K K",
+	"ss SS ß ẞ",
+	"abc ABC aBc",
+	"aaabbbccc",
+	"abab abc c",
+	"été αβγ ١٢",
+	"   \t\r\n",
+	"x.+*?()|[]{}^$\\ # #",
+}
+
+func checkGoPattern(t *testing.T, expr, input string) {
+	std := regexp.MustCompile(expr)
+	re, err := CompileGo(expr)
+	if err != nil {
+		t.Errorf("CompileGo(%q): unexpected error: %v", expr, err)
+		return
+	}
+	if actual, expected := re.FindAllStringSubmatchIndex(input, -1), std.FindAllStringSubmatchIndex(input, -1); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%q.FindAllStringSubmatchIndex(%q) = %v; want %v", expr, input, actual, expected)
+	}
+	if actual, expected := re.MatchString(input), std.MatchString(input); actual != expected {
+		t.Errorf("%q.MatchString(%q) = %v; want %v", expr, input, actual, expected)
+	}
+	if actual, expected := re.ReplaceAllString(input, "<>"), std.ReplaceAllString(input, "<>"); actual != expected {
+		t.Errorf("%q.ReplaceAllString(%q) = %q; want %q", expr, input, actual, expected)
+	}
+}
+
+func TestCompileGo(t *testing.T) {
+	for _, expr := range goPatterns {
+		for _, input := range goInputs {
+			checkGoPattern(t, expr, input)
+		}
+	}
+	re := MustCompileGo(`(?P<first>a+)(b)?(?P<last>c*)`)
+	if names, expected := re.namedGroupInfo, (NamedGroupInfo{"first": 1, "last": 3}); !reflect.DeepEqual(names, expected) {
+		t.Errorf("named groups = %v; want %v", names, expected)
+	}
+}
+
+func TestCompileGoRandom(t *testing.T) {
+	patterns := []string{`a+b*`, `(a|ab)(c|bcd)?`, `^(?:a|b)*$`, `(?m)^b+$`, `\b\w`, `x*`, `(?i)A[b-c]`}
+	alphabet := []string{"a", "b", "c", "d", "A", "B", " ", "\n", "x"}
+	random := rand.New(rand.NewSource(12))
+	for _, expr := range patterns {
+		for i := 0; i < 200; i++ {
+			input := ""
+			for j := random.Intn(12); j > 0; j-- {
+				input += alphabet[random.Intn(len(alphabet))]
+			}
+			checkGoPattern(t, expr, input)
+		}
+	}
+}
+
+func TestCompileGoErrors(t *testing.T) {
+	for _, expr := range []string{`(`, `a**`, `\8`, `a{1001}`, `(?P<1a>x)`} {
+		if _, err := CompileGo(expr); err == nil {
+			t.Errorf("CompileGo(%q): expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rubex
+
+import (
+	"io"
+	"regexp"
+)
+
+// Matcher reports whether text contains a match.
+type Matcher interface {
+	Match(b []byte) bool
+	MatchString(s string) bool
+	MatchReader(r io.RuneReader) bool
+}
+
+// Finder locates matches and their submatches, with the methods and results
+// of Go's regexp package: the FindAll methods return at most n matches, or
+// all of them when n is negative, and their Index variants return the start
+// and end of each match.
+type Finder interface {
+	Find(b []byte) []byte
+	FindIndex(b []byte) []int
+	FindString(s string) string
+	FindStringIndex(s string) []int
+	FindSubmatch(b []byte) [][]byte
+	FindSubmatchIndex(b []byte) []int
+	FindStringSubmatch(s string) []string
+	FindStringSubmatchIndex(s string) []int
+	FindAll(b []byte, n int) [][]byte
+	FindAllIndex(b []byte, n int) [][]int
+	FindAllString(s string, n int) []string
+	FindAllStringIndex(s string, n int) [][]int
+	FindAllSubmatch(b []byte, n int) [][][]byte
+	FindAllSubmatchIndex(b []byte, n int) [][]int
+	FindAllStringSubmatch(s string, n int) [][]string
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+	NumSubexp() int
+	SubexpNames() []string
+	SubexpIndex(name string) int
+}
+
+// Replacer replaces every match. The replacement templates of ReplaceAll and
+// ReplaceAllString are the engine's own: \1 and \k<name> for a Regexp, $1 and
+// ${name} for Go's regexp. The Literal variants insert the replacement as it
+// is on both.
+type Replacer interface {
+	ReplaceAll(src, repl []byte) []byte
+	ReplaceAllString(src, repl string) string
+	ReplaceAllLiteral(src, repl []byte) []byte
+	ReplaceAllLiteralString(src, repl string) string
+	ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte
+	ReplaceAllStringFunc(src string, repl func(string) string) string
+}
+
+// Interface is everything a Regexp and a Go regexp have in common, so code
+// can take either engine.
+type Interface interface {
+	Matcher
+	Finder
+	Replacer
+	String() string
+}
+
+// StdRegexp adapts a regexp from Go's regexp package to Interface.
+type StdRegexp struct {
+	*regexp.Regexp
+}
+
+// Std wraps re.
+func Std(re *regexp.Regexp) StdRegexp {
+	return StdRegexp{re}
+}
+
+var (
+	_ Interface = (*Regexp)(nil)
+	_ Interface = StdRegexp{}
+)
@@ -0,0 +1,98 @@
+package rubex
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Patterns written the same way for both engines, with the same meaning.
+var commonPatterns = []struct {
+	pattern, input string
+	match          bool
+	all            []string
+	replaced       string
+}{
+	{`[a-c]+`, "xxabcaxb", true, []string{"abca", "b"}, "xx<abca>x<b>"},
+	{`(\w+)@(\w+)\.com`, "a@b.com, c@d.com", true, []string{"a@b.com", "c@d.com"}, "<a@b.com>, <c@d.com>"},
+	{`x+`, "abc", false, nil, "abc"},
+	{`\d{2}`, "12345", true, []string{"12", "34"}, "<12><34>5"},
+}
+
+func checkInterface(t *testing.T, name string, compile func(string) Interface) {
+	for _, test := range commonPatterns {
+		var re Interface = compile(test.pattern)
+		if match := re.MatchString(test.input); match != test.match {
+			t.Errorf("%s: %q.MatchString(%q) = %v; want %v", name, test.pattern, test.input, match, test.match)
+		}
+		if match := re.MatchReader(strings.NewReader(test.input)); match != test.match {
+			t.Errorf("%s: %q.MatchReader(%q) = %v; want %v", name, test.pattern, test.input, match, test.match)
+		}
+		if all := re.FindAllString(test.input, -1); !reflect.DeepEqual(all, test.all) {
+			t.Errorf("%s: %q.FindAllString(%q) = %q; want %q", name, test.pattern, test.input, all, test.all)
+		}
+		if replaced := re.ReplaceAllStringFunc(test.input, func(s string) string { return "<" + s + ">" }); replaced != test.replaced {
+			t.Errorf("%s: %q.ReplaceAllStringFunc(%q) = %q; want %q", name, test.pattern, test.input, replaced, test.replaced)
+		}
+		literal := re.ReplaceAllStringFunc(test.input, func(string) string { return `$1\1` })
+		if replaced := re.ReplaceAllLiteralString(test.input, `$1\1`); replaced != literal {
+			t.Errorf("%s: %q.ReplaceAllLiteralString(%q) = %q; want %q", name, test.pattern, test.input, replaced, literal)
+		}
+		if re.String() != test.pattern {
+			t.Errorf("%s: String() = %q; want %q", name, re.String(), test.pattern)
+		}
+	}
+}
+
+func TestInterface(t *testing.T) {
+	checkInterface(t, "rubex", func(pattern string) Interface { return MustCompile(pattern) })
+	checkInterface(t, "regexp", func(pattern string) Interface { return Std(regexp.MustCompile(pattern)) })
+}
+
+func TestInterfaceSubmatches(t *testing.T) {
+	engines := []Finder{MustCompile(`(?<user>\w+)@(?<host>\w+)`), Std(regexp.MustCompile(`(?P<user>\w+)@(?P<host>\w+)`))}
+	expected := [][]string{{"a@b", "a", "b"}, {"c@d", "c", "d"}}
+	for _, re := range engines {
+		if re.NumSubexp() != 2 {
+			t.Errorf("%T: NumSubexp() = %d; want 2", re, re.NumSubexp())
+		}
+		if names := re.SubexpNames(); !reflect.DeepEqual(names, []string{"", "user", "host"}) {
+			t.Errorf("%T: SubexpNames() = %q", re, names)
+		}
+		if index := re.SubexpIndex("host"); index != 2 {
+			t.Errorf("%T: SubexpIndex(\"host\") = %d; want 2", re, index)
+		}
+		if actual := re.FindAllStringSubmatch("a@b c@d", -1); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%T: FindAllStringSubmatch = %q; want %q", re, actual, expected)
+		}
+	}
+}
+
+func TestInterfaceFindAllLimit(t *testing.T) {
+	engines := []Finder{MustCompile(`a(b)?`), Std(regexp.MustCompile(`a(b)?`))}
+	src := "xxaabaa"
+	for _, n := range []int{0, 1, 2, 10, -1} {
+		var results [][]interface{}
+		for _, re := range engines {
+			results = append(results, []interface{}{
+				re.FindAllString(src, n),
+				re.FindAllStringIndex(src, n),
+				re.FindAll([]byte(src), n),
+				re.FindAllIndex([]byte(src), n),
+				re.FindAllStringSubmatch(src, n),
+				re.FindAllStringSubmatchIndex(src, n),
+				re.FindAllSubmatch([]byte(src), n),
+				re.FindAllSubmatchIndex([]byte(src), n),
+			})
+		}
+		for i := range results[0] {
+			if !reflect.DeepEqual(results[0][i], results[1][i]) {
+				t.Errorf("n = %d, method %d: %v on rubex; %v on regexp", n, i, results[0][i], results[1][i])
+			}
+		}
+	}
+	if actual := MustCompile("a").FindAllString("xxaaa", 2); !reflect.DeepEqual(actual, []string{"a", "a"}) {
+		t.Errorf(`FindAllString("xxaaa", 2) = %q`, actual)
+	}
+}
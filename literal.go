@@ -0,0 +1,293 @@
+package rubex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LiteralError describes a regex literal that cannot be parsed. Offset is the
+// byte offset in the literal where the problem was found.
+type LiteralError struct {
+	Literal string
+	Offset  int
+	Msg     string
+}
+
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("rubex: invalid literal %q at offset %d: %s", e.Literal, e.Offset, e.Msg)
+}
+
+// literal is a parsed regex literal, ready to compile.
+type literal struct {
+	pattern  string
+	option   Option
+	syntax   Syntax
+	encoding Encoding
+}
+
+// Ruby's %r literals may use a bracket pair as delimiters; brackets of the
+// same kind nest inside the pattern.
+var literalClosers = map[byte]byte{'(': ')', '[': ']', '{': '}', '<': '>'}
+
+// CompileLiteral compiles a Ruby regex literal such as /^\/m\/(?<id>\d+)/i or
+// %r{a/b}x. The flags i, m and x set the matching ONIG_OPTION_* options, o is
+// accepted and ignored, and n, e, s and u select the binary, EUC-JP,
+// Shift_JIS and UTF-8 encodings. Interpolation with #{...} is not supported.
+//
+// A /.../ literal whose flags include g, y, d or v is taken to be a
+// JavaScript literal and compiled as by CompileJSLiteral.
+func CompileLiteral(src string) (*Regexp, error) {
+	var lit *literal
+	var err error
+	if strings.HasPrefix(src, "/") && strings.ContainsAny(trailingFlags(src), "gydv") {
+		lit, err = parseJSLiteral(src)
+	} else {
+		lit, err = parseRubyLiteral(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lit.compile()
+}
+
+func MustCompileLiteral(src string) *Regexp {
+	regexp, error := CompileLiteral(src)
+	if error != nil {
+		panic("regexp: compiling " + src + ": " + error.Error())
+	}
+	return regexp
+}
+
+// CompileJSLiteral compiles a JavaScript regex literal such as /a.b/gims. The
+// pattern is compiled with ONIG_SYNTAX_PERL_NT: i ignores case, m lets ^ and
+// $ match at line breaks and s lets . match a newline. The g, y and d flags
+// control how JavaScript iterates over matches and are accepted and ignored;
+// u and v are accepted since patterns are always Unicode aware.
+func CompileJSLiteral(src string) (*Regexp, error) {
+	lit, err := parseJSLiteral(src)
+	if err != nil {
+		return nil, err
+	}
+	return lit.compile()
+}
+
+func MustCompileJSLiteral(src string) *Regexp {
+	regexp, error := CompileJSLiteral(src)
+	if error != nil {
+		panic("regexp: compiling " + src + ": " + error.Error())
+	}
+	return regexp
+}
+
+func (lit *literal) compile() (*Regexp, error) {
+	return NewRegexpWithEncoding(lit.pattern, lit.option, lit.syntax, lit.encoding)
+}
+
+// trailingFlags returns the run of ASCII letters at the end of src.
+func trailingFlags(src string) string {
+	i := len(src)
+	for i > 0 && isASCIILetter(src[i-1]) {
+		i--
+	}
+	return src[i:]
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func parseRubyLiteral(src string) (*literal, error) {
+	lit, n, err := scanRubyLiteral(src)
+	if err == nil && n < len(src) {
+		return nil, &LiteralError{src, n, fmt.Sprintf("unknown flag %q", src[n])}
+	}
+	return lit, err
+}
+
+// scanRubyLiteral parses the Ruby literal at the start of src and returns it
+// with the length of the literal and its flags.
+func scanRubyLiteral(src string) (*literal, int, error) {
+	var open, close byte
+	start := 0
+	switch {
+	case strings.HasPrefix(src, "/"):
+		open, close, start = '/', '/', 1
+	case strings.HasPrefix(src, "%r"):
+		if len(src) < 3 {
+			return nil, 0, &LiteralError{src, len(src), "missing delimiter after %r"}
+		}
+		open, start = src[2], 3
+		if c, ok := literalClosers[open]; ok {
+			close = c
+		} else if open > ' ' && open < 0x7f && !isASCIILetter(open) && (open < '0' || open > '9') {
+			close = open
+		} else {
+			return nil, 0, &LiteralError{src, 2, fmt.Sprintf("invalid delimiter %q", open)}
+		}
+	default:
+		return nil, 0, &LiteralError{src, 0, "literal must start with / or %r"}
+	}
+
+	depth := 0
+	end := -1
+	for i := start; i < len(src) && end < 0; i++ {
+		switch c := src[i]; {
+		case c == '\\':
+			i++
+		case c == '#' && i+1 < len(src) && src[i+1] == '{':
+			return nil, 0, &LiteralError{src, i, "interpolation is not supported"}
+		case c == close && depth == 0:
+			end = i
+		case c == close:
+			depth--
+		case c == open:
+			depth++
+		}
+	}
+	if end < 0 {
+		return nil, 0, &LiteralError{src, len(src), "unterminated literal"}
+	}
+
+	lit := &literal{pattern: src[start:end], syntax: ONIG_SYNTAX_RUBY, encoding: ONIG_ENCODING_DEFAULT}
+	encodingFlag := -1
+	i := end + 1
+	for ; i < len(src) && isASCIILetter(src[i]); i++ {
+		switch c := src[i]; c {
+		case 'i':
+			lit.option |= ONIG_OPTION_IGNORECASE
+		case 'm':
+			lit.option |= ONIG_OPTION_MULTILINE
+		case 'x':
+			lit.option |= ONIG_OPTION_EXTEND
+		case 'o':
+		case 'n', 'e', 's', 'u':
+			if encodingFlag >= 0 && src[encodingFlag] != c {
+				return nil, 0, &LiteralError{src, i, fmt.Sprintf("flag %q conflicts with %q", c, src[encodingFlag])}
+			}
+			encodingFlag = i
+			lit.encoding = rubyLiteralEncodings[c]
+		default:
+			return nil, 0, &LiteralError{src, i, fmt.Sprintf("unknown flag %q", c)}
+		}
+	}
+	return lit, i, nil
+}
+
+var rubyLiteralEncodings = map[byte]Encoding{
+	'n': ONIG_ENCODING_BINARY,
+	'e': ONIG_ENCODING_EUC_JP,
+	's': ONIG_ENCODING_SJIS,
+	'u': ONIG_ENCODING_UTF8,
+}
+
+func parseJSLiteral(src string) (*literal, error) {
+	if !strings.HasPrefix(src, "/") {
+		return nil, &LiteralError{src, 0, "literal must start with /"}
+	}
+	inClass := false
+	end := -1
+	for i := 1; i < len(src) && end < 0; i++ {
+		switch c := src[i]; {
+		case c == '\n' || c == '\r':
+			return nil, &LiteralError{src, i, "line break in literal"}
+		case c == '\\':
+			i++
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			end = i
+		}
+	}
+	if end < 0 {
+		return nil, &LiteralError{src, len(src), "unterminated literal"}
+	}
+	if end == 1 {
+		return nil, &LiteralError{src, 1, "empty pattern"}
+	}
+
+	lit := &literal{pattern: src[1:end], syntax: ONIG_SYNTAX_PERL_NT, encoding: ONIG_ENCODING_UTF8}
+	for i := end + 1; i < len(src); i++ {
+		c := src[i]
+		if strings.IndexByte(src[end+1:i], c) >= 0 {
+			return nil, &LiteralError{src, i, fmt.Sprintf("duplicate flag %q", c)}
+		}
+		switch c {
+		case 'i':
+			lit.option |= ONIG_OPTION_IGNORECASE
+		case 'm':
+			lit.option |= ONIG_OPTION_NEGATE_SINGLELINE
+		case 's':
+			lit.option |= ONIG_OPTION_MULTILINE
+		case 'u', 'v':
+			if strings.ContainsAny(src[end+1:i], "uv") {
+				return nil, &LiteralError{src, i, "flags u and v cannot be combined"}
+			}
+		case 'g', 'y', 'd':
+		default:
+			return nil, &LiteralError{src, i, fmt.Sprintf("unknown flag %q", c)}
+		}
+	}
+	return lit, nil
+}
+
+// Literal returns a regex literal for the pattern: in JavaScript form, to be
+// read back by CompileJSLiteral, for ONIG_SYNTAX_PERL_NT patterns and in Ruby
+// form otherwise. Options and encodings that have no literal flag are left
+// out.
+func (re *Regexp) Literal() string {
+	if re.syntax == ONIG_SYNTAX_PERL_NT {
+		flags := ""
+		for _, f := range jsLiteralFlags {
+			if re.Options()&f.option != 0 {
+				flags += string(f.flag)
+			}
+		}
+		return "/" + escapeLiteral(re.pattern, true) + "/" + flags
+	}
+	flags := re.Options().Flags()
+	for flag, encoding := range rubyLiteralEncodings {
+		if encoding == re.encoding && encoding != ONIG_ENCODING_DEFAULT {
+			flags += string(flag)
+		}
+	}
+	return "/" + escapeLiteral(re.pattern, false) + "/" + flags
+}
+
+// the JavaScript flag letters, in the order JavaScript writes them
+var jsLiteralFlags = []struct {
+	option Option
+	flag   byte
+}{
+	{ONIG_OPTION_IGNORECASE, 'i'},
+	{ONIG_OPTION_NEGATE_SINGLELINE, 'm'},
+	{ONIG_OPTION_MULTILINE, 's'},
+}
+
+// escapeLiteral escapes the slashes in pattern that would end a /.../
+// literal, and in Ruby form the #{ that would start an interpolation.
+// JavaScript literals cannot hold line breaks, so those are written as
+// escapes too.
+func escapeLiteral(pattern string, js bool) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(pattern[i])
+		case c == '/':
+			b.WriteString(`\/`)
+		case c == '#' && !js && i+1 < len(pattern) && pattern[i+1] == '{':
+			b.WriteString(`\#`)
+		case c == '\n' && js:
+			b.WriteString(`\n`)
+		case c == '\r' && js:
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"testing"
+)
+
+var literalTests = []struct {
+	literal string
+	input   string
+	match   string
+	format  string
+}{
+	{`/^\/m\/(?<id>\d+)/i`, "/M/42", "/M/42", `/^\/m\/(?<id>\d+)/i`},
+	{`%r{a/b}x`, "a/b", "a/b", `/a\/b/x`},
+	{`%r{a{2}/}`, "aa/", "aa/", `/a{2}\//`},
+	{`%r!a\!b!`, "a!b", "a!b", `/a\!b/`},
+	{`/a.b/m`, "a\nb", "a\nb", `/a.b/m`},
+	{`/a.b/`, "a\nb axb", "axb", `/a.b/`},
+	{`/a b # comment
+	c/xi`, "ABC", "ABC", "/a b # comment\n\tc/ix"},
+	{`/./n`, "\xe9", "\xe9", `/./n`},
+	{`/./u`, "é", "é", `/./`},
+	{`//o`, "a", "", `//`},
+	// JavaScript literals
+	{`/[/]+/g`, "a//b", "//", `/[\/]+/`},
+	{`/^b/gm`, "a\nb", "b", `/^b/m`},
+	{`/^b/g`, "a\nb", "", `/^b/`},
+	{`/a.b/gs`, "a\nB", "", `/a.b/s`},
+	{`/a.b/gis`, "a\nB", "a\nB", `/a.b/is`},
+	{`/(?<x>a)\k<x>/dy`, "aa", "aa", `/(?<x>a)\k<x>/`},
+}
+
+func TestCompileLiteral(t *testing.T) {
+	for _, tc := range literalTests {
+		re, err := CompileLiteral(tc.literal)
+		if err != nil {
+			t.Errorf("CompileLiteral(%q): unexpected error: %v", tc.literal, err)
+			continue
+		}
+		if actual := re.FindString(tc.input); actual != tc.match {
+			t.Errorf("%q.FindString(%q) = %q; want %q", tc.literal, tc.input, actual, tc.match)
+		}
+		if actual := re.Literal(); actual != tc.format {
+			t.Errorf("%q.Literal() = %q; want %q", tc.literal, actual, tc.format)
+		}
+		// the formatted literal compiles back to an equivalent pattern
+		compile := CompileLiteral
+		if re.Syntax() == ONIG_SYNTAX_PERL_NT {
+			compile = CompileJSLiteral
+		}
+		again, err := compile(re.Literal())
+		if err != nil {
+			t.Errorf("CompileLiteral(%q): unexpected error: %v", re.Literal(), err)
+			continue
+		}
+		if again.Literal() != re.Literal() || again.FindString(tc.input) != tc.match {
+			t.Errorf("%q does not round-trip: %q", tc.literal, again.Literal())
+		}
+	}
+}
+
+func TestCompileJSLiteral(t *testing.T) {
+	re := MustCompileJSLiteral(`/^b$/im`)
+	if actual := re.FindString("a\nB\nc"); actual != "B" {
+		t.Errorf("FindString = %q", actual)
+	}
+	if re.Syntax() != ONIG_SYNTAX_PERL_NT {
+		t.Errorf("Syntax() = %s", re.Syntax())
+	}
+	// without flags JS literals are compiled as JS, not Ruby
+	if actual := MustCompileJSLiteral(`/^b/`).FindString("a\nb"); actual != "" {
+		t.Errorf("FindString = %q", actual)
+	}
+	if _, err := CompileJSLiteral(`%r{a}`); err == nil {
+		t.Errorf("expected an error for a Ruby literal")
+	}
+}
+
+func TestLiteralErrors(t *testing.T) {
+	invalid := map[string]int{
+		`abc`:      0,
+		`/abc`:     4,
+		`/abc/iq`:  6,
+		`/abc/ne`:  6,
+		`/a#{b}/`:  2,
+		`%r`:       2,
+		`%ra/a`:    2,
+		`%r{a{b}`:  7,
+		`/abc/gig`: 7,
+		`/abc/guv`: 7,
+		`/abc/gx`:  6,
+		"/a\nb/g":  2,
+		"/[/g":     4,
+	}
+	for src, offset := range invalid {
+		_, err := CompileLiteral(src)
+		if e, ok := err.(*LiteralError); !ok || e.Offset != offset {
+			t.Errorf("CompileLiteral(%q) = %v; want an error at offset %d", src, err, offset)
+		}
+	}
+	// a well-formed literal with an invalid pattern fails to compile
+	if _, err := CompileLiteral(`/(/`); err == nil {
+		t.Errorf("expected a compile error")
+	} else if _, ok := err.(*LiteralError); ok {
+		t.Errorf("expected a compile error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package rubex
+
+import (
+	"errors"
+)
+
+// Longest makes future searches prefer leftmost-longest matches, as
+// regexp.Regexp.Longest does. When searching text, a Regexp normally returns
+// the match that starts earliest, and of those the one its alternatives and
+// repeats reach first, as Ruby and Perl do. After Longest it returns, of the
+// matches that start earliest, the longest. Compiling with
+// ONIG_OPTION_FIND_LONGEST has the same effect.
+//
+// Oniguruma's own longest-match search only compares the first match found
+// at each position, so the longest match is found with the pure-Go engine,
+// which tries every way the pattern can match there, skipping the ones it
+// has already seen fail. That only supports Ruby syntax on UTF-8 text, and
+// with backreferences or subexpression calls, which keep it from skipping,
+// may take exponential time on patterns that can match the same text in
+// exponentially many ways. For patterns it does not support, Longest returns
+// the error compiling with ONIG_OPTION_FIND_LONGEST would and leaves the
+// Regexp as it was.
+//
+// Longest modifies the Regexp and may not be called concurrently with any
+// other methods.
+func (re *Regexp) Longest() error {
+	return re.setLongest()
+}
+
+// setLongest prepares the engine that finds leftmost-longest matches.
+func (re *Regexp) setLongest() error {
+	if re.longest != nil {
+		return nil
+	}
+	if re.syntax != ONIG_SYNTAX_RUBY || re.encoding != ONIG_ENCODING_UTF8 {
+		return errors.New("rubex: leftmost-longest matching needs " + ONIG_SYNTAX_RUBY.String() + " on " + ONIG_ENCODING_UTF8.String() + " text")
+	}
+	bt, err := newBacktracker(re.pattern, re.option&^ONIG_OPTION_FIND_LONGEST)
+	if unsupported, ok := err.(*unsupportedError); ok {
+		return errors.New("rubex: " + unsupported.construct + " is not supported with leftmost-longest matching")
+	} else if err != nil {
+		return err
+	}
+	re.longest = bt
+	return nil
+}
@@ -0,0 +1,188 @@
+package rubex
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Go's regexp patterns whose leftmost-longest matches differ from their
+// leftmost-first ones on longestInputs.
+var longestPatterns = []string{
+	`a|ab`,
+	`(a|ab)(c|bcd)?(d*)`,
+	`a*?`,
+	`x*|abc`,
+	`(a+?)(b*)`,
+	`b|xb+`,
+	`if|ifelse|i`,
+	`\d+|\d+\.\d+`,
+	`(a|ab|abc)*`,
+	`(?i)Ab|aBc`,
+	`\b(a|ab)\b`,
+	`(?:a|ab)(?:c|bcd)`,
+}
+
+var longestInputs = []string{
+	"",
+	"ab",
+	"abcd abc",
+	"aaa",
+	"aabb xbbb",
+	"if ifelse i",
+	"1.5 22 3.14",
+	"ABC abc",
+	"a ab",
+}
+
+func TestLongest(t *testing.T) {
+	for _, expr := range longestPatterns {
+		std := regexp.MustCompile(expr)
+		std.Longest()
+		re := MustCompileGo(expr)
+		re.Longest()
+		for _, input := range longestInputs {
+			var actual [][]int
+			for _, loc := range re.FindAllStringIndex(input, -1) {
+				actual = append(actual, loc[:2])
+			}
+			if expected := std.FindAllStringIndex(input, -1); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%q.FindAllStringIndex(%q) = %v; want %v", expr, input, actual, expected)
+			}
+			if actual, expected := re.FindStringIndex(input), std.FindStringIndex(input); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%q.FindStringIndex(%q) = %v; want %v", expr, input, actual, expected)
+			}
+			if actual, expected := re.ReplaceAllString(input, "<>"), std.ReplaceAllString(input, "<>"); actual != expected {
+				t.Errorf("%q.ReplaceAllString(%q) = %q; want %q", expr, input, actual, expected)
+			}
+		}
+	}
+}
+
+func TestLongestOption(t *testing.T) {
+	re := MustCompile(`(a|ab)(c|bcd)?`)
+	if actual := re.FindString("abc"); actual != "a" {
+		t.Errorf("FindString = %q before Longest", actual)
+	}
+	re.Longest()
+	expected := []int{0, 3, 0, 2, 2, 3}
+	if actual := re.FindStringSubmatchIndex("abc"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FindStringSubmatchIndex = %v; want %v", actual, expected)
+	}
+	re = MustCompileWithOption(`(?<w>a|ab)`, ONIG_OPTION_FIND_LONGEST)
+	if actual := re.Gsub("ab a ab", "<\\k<w>>"); actual != "<ab> <a> <ab>" {
+		t.Errorf("Gsub = %q", actual)
+	}
+	if actual := re.ReplaceAllStringFunc("ab", func(s string) string { return "[" + s + "]" }); actual != "[ab]" {
+		t.Errorf("ReplaceAllStringFunc = %q", actual)
+	}
+	if actual := re.FindAllStringWithOptions("ab\nab", -1, ONIG_OPTION_NOTBOL); !reflect.DeepEqual(actual, []string{"ab", "ab"}) {
+		t.Errorf("FindAllStringWithOptions = %q", actual)
+	}
+	re = MustCompileWithOption(`\Ga|\Gab`, ONIG_OPTION_FIND_LONGEST)
+	if actual := re.FindAllString("abab", -1); !reflect.DeepEqual(actual, []string{"ab", "ab"}) {
+		t.Errorf(`\G FindAllString = %q`, actual)
+	}
+}
+
+func TestLongestUnsupported(t *testing.T) {
+	if _, err := CompileWithSyntax(`a|ab`, ONIG_OPTION_FIND_LONGEST, ONIG_SYNTAX_PERL); err == nil {
+		t.Errorf("expected an error for a Perl pattern")
+	}
+	if _, err := CompileWithOption(`a|a\Kb`, ONIG_OPTION_FIND_LONGEST); err == nil {
+		t.Errorf(`expected an error for \K`)
+	}
+	re, err := CompileWithSyntax(`a|ab`, ONIG_OPTION_NONE, ONIG_SYNTAX_PERL)
+	if err != nil {
+		// builds without cgo only have Ruby syntax
+		return
+	}
+	if err := re.Longest(); err == nil || !strings.HasPrefix(err.Error(), "rubex: ") {
+		t.Errorf("Longest() = %v for a Perl pattern", err)
+	}
+	if actual := re.FindString("ab"); actual != "a" {
+		t.Errorf("FindString = %q after a failed Longest", actual)
+	}
+	re = MustCompile(`a|a\Kb`)
+	if err := re.Longest(); err == nil || !strings.HasPrefix(err.Error(), "rubex: ") {
+		t.Errorf(`Longest() = %v for \K`, err)
+	}
+	if actual := re.FindString("ab"); actual != "a" {
+		t.Errorf(`FindString = %q after a failed Longest`, actual)
+	}
+}
+
+// Patterns that match text in exponentially many ways, which the search for
+// the longest match must not all try.
+var longestPathological = []struct {
+	pattern, input string
+	match          []int
+}{
+	{`(a|aa)*`, strings.Repeat("a", 100), []int{0, 100, 99, 100}},
+	{`\w*\w*\w*x`, strings.Repeat("a", 400) + "x", []int{0, 401}},
+	{`(a*)*b`, strings.Repeat("a", 100) + "b", []int{0, 101, 100, 100}},
+	{`((a|b)+|c)*d`, strings.Repeat("ab", 100) + "d", []int{0, 201, 0, 200, 199, 200}},
+}
+
+func TestLongestPathological(t *testing.T) {
+	for _, test := range longestPathological {
+		re := MustCompile(test.pattern)
+		re.Longest()
+		done := make(chan []int, 1)
+		go func() {
+			done <- re.FindStringSubmatchIndex(test.input)
+		}()
+		select {
+		case actual := <-done:
+			if !reflect.DeepEqual(actual, test.match) {
+				t.Errorf("%q.FindStringSubmatchIndex = %v; want %v", test.pattern, actual, test.match)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q.FindStringSubmatchIndex did not finish", test.pattern)
+		}
+	}
+}
+
+// TestLongestMemo checks that skipping the states that failed before finds
+// the same matches and groups as trying every way of matching.
+func TestLongestMemo(t *testing.T) {
+	patterns := append([]string{
+		`(?>a|ab)c?`,
+		`(a|ab)++`,
+		`(?=(a+))a`,
+		`(?<=a|ab)(b*)`,
+		`(a|ab)(?<=b)`,
+		`(?!ab)(a|ab)`,
+		`((a)|b)*`,
+		`(a?)*`,
+		`(|a)*`,
+		`(a*?)+?b?`,
+		`(a|)+?c`,
+		`(a|aa){2,}`,
+		`(?:a{1,3}){2}`,
+		`((a?)(b?))*`,
+		`\Ga|b`,
+	}, longestPatterns...)
+	inputs := append([]string{"aaaa", "ababcab", "aabacbbc", "bbbaaab"}, longestInputs...)
+	for _, pattern := range patterns {
+		bt, err := newBacktracker(pattern, ONIG_OPTION_NONE)
+		if err != nil {
+			t.Fatalf("%q: %v", pattern, err)
+		}
+		exhaustive := *bt
+		exhaustive.memoize = false
+		for _, input := range inputs {
+			b := []byte(input)
+			for pos := 0; pos <= len(b); pos++ {
+				for _, offset := range []int{0, pos} {
+					actual := bt.longestAt(b, len(b), offset, pos, ONIG_OPTION_NONE)
+					if expected := exhaustive.longestAt(b, len(b), offset, pos, ONIG_OPTION_NONE); !reflect.DeepEqual(actual, expected) {
+						t.Errorf("%q in %q at %d from %d: %v; want %v", pattern, input, pos, offset, actual, expected)
+					}
+				}
+			}
+		}
+	}
+}
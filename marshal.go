@@ -0,0 +1,191 @@
+package rubex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the options a Ruby literal has flag letters for
+const literalFlagOptions = ONIG_OPTION_IGNORECASE | ONIG_OPTION_MULTILINE | ONIG_OPTION_EXTEND
+
+// MarshalText implements encoding.TextMarshaler. The text is a Ruby regex
+// literal, such as /a\/b/im, followed by space-separated fields for what a
+// literal has no flags for:
+//
+//	syntax=ONIG_SYNTAX_PERL     a syntax other than ONIG_SYNTAX_RUBY
+//	encoding=ISO-8859-1         an encoding other than UTF-8, binary, EUC-JP and Shift_JIS
+//	options=ONIG_OPTION_...     other options, joined with |
+//
+// Slashes, and #{ that would start an interpolation, are escaped in the
+// pattern. A pattern compiled with a custom syntax marshals with the name the
+// syntax was registered under, which must not contain a space.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	option := re.option | re.implied
+	if re.longest != nil {
+		option |= ONIG_OPTION_FIND_LONGEST
+	}
+	text := "/" + escapeLiteral(re.pattern, false) + "/" + option.Flags()
+	encodingFlag := false
+	for flag, encoding := range rubyLiteralEncodings {
+		if encoding == re.encoding {
+			encodingFlag = true
+			if encoding != ONIG_ENCODING_DEFAULT {
+				text += string(flag)
+			}
+		}
+	}
+	if re.syntax != ONIG_SYNTAX_RUBY {
+		name := re.syntax.String()
+		if strings.Contains(name, " ") {
+			return nil, errors.New("rubex: cannot marshal a pattern for syntax " + name)
+		}
+		text += " syntax=" + name
+	}
+	if !encodingFlag {
+		text += " encoding=" + re.encoding.String()
+	}
+	if other := option &^ literalFlagOptions; other != 0 {
+		text += " options=" + other.String()
+	}
+	return []byte(text), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It compiles text as
+// written by MarshalText, replacing the Regexp's pattern. The fields after
+// the literal are optional, and text that does not start with / or %r is
+// compiled as a plain pattern with the default options, syntax and encoding,
+// as by Compile.
+//
+// UnmarshalText modifies the Regexp and may not be called concurrently with
+// any other methods. A native regex the Regexp held is freed.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	compiled, err := unmarshalRegexp(string(text))
+	if err != nil {
+		return err
+	}
+	if re.owner != nil || re.regex != nil {
+		re.Free()
+	}
+	// The Regexp may be a field of a larger struct, which cannot be given a
+	// finalizer, so the compiled Regexp keeps the native regex and its own.
+	*re = *compiled
+	re.owner = compiled
+	return nil
+}
+
+func unmarshalRegexp(text string) (*Regexp, error) {
+	if !strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "%r") {
+		return Compile(text)
+	}
+	lit, n, err := scanRubyLiteral(text)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(text) && text[n] != ' ' {
+		return nil, &LiteralError{text, n, fmt.Sprintf("unknown flag %q", text[n])}
+	}
+	for n < len(text) {
+		field := text[n+1:]
+		if end := strings.IndexByte(field, ' '); end >= 0 {
+			field = field[:end]
+		}
+		key, value, _ := strings.Cut(field, "=")
+		var ok bool
+		switch key {
+		case "syntax":
+			lit.syntax, ok = lookupSyntax(value)
+		case "encoding":
+			lit.encoding, ok = lookupEncoding(value)
+		case "options":
+			var option Option
+			option, ok = lookupOptions(value)
+			lit.option |= option
+		}
+		if !ok {
+			return nil, &LiteralError{text, n + 1, fmt.Sprintf("invalid field %q", field)}
+		}
+		n += 1 + len(field)
+	}
+	return lit.compile()
+}
+
+// lookupSyntax returns the predefined or registered syntax with the name.
+func lookupSyntax(name string) (Syntax, bool) {
+	for syntax, syntaxName := range syntaxNames {
+		if syntaxName == name {
+			return Syntax(syntax), true
+		}
+	}
+	customSyntaxes.RLock()
+	defer customSyntaxes.RUnlock()
+	for i, syntaxName := range customSyntaxes.names {
+		if syntaxName == name {
+			return firstCustomSyntax + Syntax(i), true
+		}
+	}
+	return 0, false
+}
+
+func lookupEncoding(name string) (Encoding, bool) {
+	for encoding, encodingName := range encodingNames {
+		if encodingName == name {
+			return Encoding(encoding), true
+		}
+	}
+	return 0, false
+}
+
+// lookupOptions parses options as Option.String writes them.
+func lookupOptions(names string) (Option, bool) {
+	var option Option
+	for _, name := range strings.Split(names, "|") {
+		found := name == "ONIG_OPTION_NONE"
+		for _, o := range optionNames {
+			if o.name == name {
+				option |= o.option
+				found = true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return option, true
+}
+
+// MarshalJSON implements json.Marshaler, writing the text of MarshalText as a
+// JSON string.
+func (re *Regexp) MarshalJSON() ([]byte, error) {
+	text, err := re.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It takes a JSON string holding
+// anything UnmarshalText accepts; null leaves the Regexp as it is.
+func (re *Regexp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return re.UnmarshalText([]byte(text))
+}
+
+// Set implements flag.Value together with String, so that a pattern can be
+// given on the command line:
+//
+//	var pattern = rubex.MustCompile(`\d+`)
+//	flag.Var(pattern, "pattern", "the `regexp` to search for")
+//
+// The value is compiled as by UnmarshalText, so -pattern='/^ab+/i' sets
+// options in literal form and -pattern='^ab+' takes a plain pattern.
+func (re *Regexp) Set(value string) error {
+	return re.UnmarshalText([]byte(value))
+}
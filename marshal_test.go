@@ -0,0 +1,178 @@
+package rubex
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"testing"
+)
+
+var marshalTests = []struct {
+	pattern  string
+	option   Option
+	syntax   Syntax
+	encoding Encoding
+	text     string
+	// native is set for syntaxes and encodings only Oniguruma has
+	native bool
+}{
+	{`a+b`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8, `/a+b/`, false},
+	{`a/b\/c`, ONIG_OPTION_IGNORECASE | ONIG_OPTION_EXTEND, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8, `/a\/b\/c/ix`, false},
+	{`#{x} #`, ONIG_OPTION_MULTILINE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8, `/\#{x} #/m`, false},
+	{`a b`, ONIG_OPTION_CAPTURE_GROUP | ONIG_OPTION_FIND_NOT_EMPTY, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8,
+		`/a b/ options=ONIG_OPTION_FIND_NOT_EMPTY|ONIG_OPTION_CAPTURE_GROUP`, false},
+	{`a|ab`, ONIG_OPTION_IGNORECASE | ONIG_OPTION_FIND_LONGEST, ONIG_SYNTAX_RUBY, ONIG_ENCODING_UTF8,
+		`/a|ab/i options=ONIG_OPTION_FIND_LONGEST`, false},
+	{`\w+`, ONIG_OPTION_NONE, ONIG_SYNTAX_RUBY, ONIG_ENCODING_BINARY, `/\w+/n`, true},
+	{`(?<x>\d)`, ONIG_OPTION_IGNORECASE, ONIG_SYNTAX_PERL_NT, ONIG_ENCODING_UTF8, `/(?<x>\d)/i syntax=ONIG_SYNTAX_PERL_NT`, true},
+	{`a\{2\}`, ONIG_OPTION_NONE, ONIG_SYNTAX_POSIX_BASIC, ONIG_ENCODING_ISO_8859_1,
+		`/a\{2\}/ syntax=ONIG_SYNTAX_POSIX_BASIC encoding=ISO-8859-1`, true},
+	{`.`, ONIG_OPTION_SINGLELINE, ONIG_SYNTAX_JAVA, ONIG_ENCODING_SJIS, `/./s syntax=ONIG_SYNTAX_JAVA options=ONIG_OPTION_SINGLELINE`, true},
+}
+
+func TestMarshalText(t *testing.T) {
+	for _, test := range marshalTests {
+		if test.native && !Features().Oniguruma {
+			continue
+		}
+		re, err := NewRegexpWithEncoding(test.pattern, test.option, test.syntax, test.encoding)
+		if err != nil {
+			t.Errorf("compiling %q: %v", test.pattern, err)
+			continue
+		}
+		text, err := re.MarshalText()
+		if err != nil || string(text) != test.text {
+			t.Errorf("%q.MarshalText() = %q, %v; want %q", test.pattern, text, err, test.text)
+			continue
+		}
+		var again Regexp
+		if err := again.UnmarshalText(text); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", text, err)
+			continue
+		}
+		if again.Options() != test.option || again.Syntax() != test.syntax || again.Encoding() != test.encoding {
+			t.Errorf("UnmarshalText(%q) = %v, %v, %v; want %v, %v, %v", text, again.Options(), again.Syntax(), again.Encoding(),
+				test.option, test.syntax, test.encoding)
+		}
+		if roundTrip, _ := again.MarshalText(); string(roundTrip) != test.text {
+			t.Errorf("UnmarshalText(%q) marshals as %q", text, roundTrip)
+		}
+	}
+}
+
+func TestMarshalLongest(t *testing.T) {
+	re := MustCompile(`a|ab`)
+	re.Longest()
+	text, _ := re.MarshalText()
+	if string(text) != `/a|ab/ options=ONIG_OPTION_FIND_LONGEST` {
+		t.Errorf("MarshalText() = %q", text)
+	}
+	if err := re.UnmarshalText(text); err != nil || re.FindString("ab") != "ab" {
+		t.Errorf("UnmarshalText(%q) = %v, finds %q", text, err, re.FindString("ab"))
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	valid := []struct {
+		text, input, match string
+	}{
+		{`a.c`, "xABC abc", "abc"},
+		{`/a.c/i`, "xABC abc", "ABC"},
+		{`%r{a/c}`, "a/c", "a/c"},
+		{`/a  b/x options=ONIG_OPTION_NONE`, "ab", "ab"},
+		{`/\d+/ syntax=ONIG_SYNTAX_RUBY encoding=UTF-8`, "x42", "42"},
+	}
+	for _, test := range valid {
+		var re Regexp
+		if err := re.UnmarshalText([]byte(test.text)); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", test.text, err)
+		} else if actual := re.FindString(test.input); actual != test.match {
+			t.Errorf("%q.FindString(%q) = %q; want %q", test.text, test.input, actual, test.match)
+		}
+	}
+	invalid := []string{
+		`(`,
+		`/a/q`,
+		`/a/;`,
+		`/a`,
+		`/a/ syntax=nope`,
+		`/a/ encoding=BINARY`,
+		`/a/ options=ONIG_OPTION_NOTBOL`,
+		`/a/ options=NOPE`,
+		`/a/  syntax=ONIG_SYNTAX_RUBY`,
+		`/a/ flavor=ruby`,
+	}
+	for _, text := range invalid {
+		re := MustCompile(`kept`)
+		if err := re.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("UnmarshalText(%q): expected an error", text)
+		} else if re.String() != `kept` || !re.MatchString("kept") {
+			t.Errorf("UnmarshalText(%q) changed the Regexp to %q after an error", text, re.String())
+		}
+	}
+}
+
+func TestUnmarshalTextReplacesPattern(t *testing.T) {
+	re := MustCompile(`a`)
+	if err := re.UnmarshalText([]byte(`/b/i`)); err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("B") || re.MatchString("a") {
+		t.Errorf("%q matches like the old pattern", re.String())
+	}
+	if err := re.UnmarshalText([]byte(`c`)); err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("c") || re.MatchString("B") {
+		t.Errorf("%q matches like the old pattern", re.String())
+	}
+	re.Free()
+	expectFreedPanic(t, "MatchString", func() { re.MatchString("c") })
+}
+
+func TestJSON(t *testing.T) {
+	type config struct {
+		Name    string
+		Pattern Regexp
+		Pointer *Regexp
+		Missing *Regexp
+	}
+	var c config
+	input := `{"Name":"x","Pattern":"/^a.c$/im","Pointer":"b+","Missing":null}`
+	if err := json.Unmarshal([]byte(input), &c); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Pattern.MatchString("x\nA\nc") || c.Pointer.FindString("abbc") != "bb" || c.Missing != nil {
+		t.Errorf("json.Unmarshal(%s) = %+v", input, c)
+	}
+	output, err := json.Marshal(&c)
+	if expected := `{"Name":"x","Pattern":"/^a.c$/im","Pointer":"/b+/","Missing":null}`; err != nil || string(output) != expected {
+		t.Errorf("json.Marshal = %s, %v; want %s", output, err, expected)
+	}
+	if err := json.Unmarshal([]byte(`{"Pattern":"("}`), &c); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+	if err := json.Unmarshal([]byte(`{"Pattern":42}`), &c); err == nil {
+		t.Errorf("expected an error for a number")
+	}
+	c.Pattern.Free()
+	c.Pointer.Free()
+}
+
+func TestFlag(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	pattern := MustCompile(`\d+`)
+	flags.Var(pattern, "pattern", "the `regexp` to search for")
+	if err := flags.Parse([]string{"-pattern", "/^ab+/i"}); err != nil {
+		t.Fatal(err)
+	}
+	if actual := pattern.FindString("ABBc"); actual != "ABB" {
+		t.Errorf("FindString = %q; want %q", actual, "ABB")
+	}
+	if err := flags.Parse([]string{"-pattern", "("}); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+	// the usage message asks a zero Regexp for its String
+	flags.PrintDefaults()
+}
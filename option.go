@@ -0,0 +1,106 @@
+package rubex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Option is a set of ONIG_OPTION_* flags a pattern is compiled with. The
+// untyped ONIG_OPTION_* constants convert to it directly, and the compile
+// functions take it, so the result of ParseOptions can be passed to them as
+// it is.
+type Option int
+
+// compileTimeOptions are the options that may be given when compiling;
+// NOTBOL, NOTEOL and POSIX_REGION only make sense for a single search.
+const compileTimeOptions = (ONIG_OPTION_CAPTURE_GROUP << 1) - 1
+
+var optionNames = []struct {
+	option Option
+	name   string
+}{
+	{ONIG_OPTION_IGNORECASE, "ONIG_OPTION_IGNORECASE"},
+	{ONIG_OPTION_EXTEND, "ONIG_OPTION_EXTEND"},
+	{ONIG_OPTION_MULTILINE, "ONIG_OPTION_MULTILINE"},
+	{ONIG_OPTION_SINGLELINE, "ONIG_OPTION_SINGLELINE"},
+	{ONIG_OPTION_FIND_LONGEST, "ONIG_OPTION_FIND_LONGEST"},
+	{ONIG_OPTION_FIND_NOT_EMPTY, "ONIG_OPTION_FIND_NOT_EMPTY"},
+	{ONIG_OPTION_NEGATE_SINGLELINE, "ONIG_OPTION_NEGATE_SINGLELINE"},
+	{ONIG_OPTION_DONT_CAPTURE_GROUP, "ONIG_OPTION_DONT_CAPTURE_GROUP"},
+	{ONIG_OPTION_CAPTURE_GROUP, "ONIG_OPTION_CAPTURE_GROUP"},
+	{ONIG_OPTION_NOTBOL, "ONIG_OPTION_NOTBOL"},
+	{ONIG_OPTION_NOTEOL, "ONIG_OPTION_NOTEOL"},
+	{ONIG_OPTION_POSIX_REGION, "ONIG_OPTION_POSIX_REGION"},
+}
+
+// the Ruby flag letters, in the order Flags writes them
+var optionFlags = []struct {
+	option Option
+	flag   byte
+}{
+	{ONIG_OPTION_IGNORECASE, 'i'},
+	{ONIG_OPTION_MULTILINE, 'm'},
+	{ONIG_OPTION_EXTEND, 'x'},
+}
+
+// ParseOptions parses Ruby-style option flags such as "imx": i ignores case,
+// m lets . match a newline and x allows whitespace and comments in the
+// pattern. The flags may come in any order.
+func ParseOptions(flags string) (Option, error) {
+	var option Option
+	for i := 0; i < len(flags); i++ {
+		found := false
+		for _, f := range optionFlags {
+			if flags[i] == f.flag {
+				option |= f.option
+				found = true
+			}
+		}
+		if !found {
+			return ONIG_OPTION_NONE, fmt.Errorf("rubex: invalid option flag %q in %q", flags[i], flags)
+		}
+	}
+	return option, nil
+}
+
+// Flags returns the Ruby-style flags for the options that have one. Options
+// without a flag letter are left out.
+func (option Option) Flags() string {
+	flags := make([]byte, 0, len(optionFlags))
+	for _, f := range optionFlags {
+		if option&f.option != 0 {
+			flags = append(flags, f.flag)
+		}
+	}
+	return string(flags)
+}
+
+func (option Option) String() string {
+	if option == ONIG_OPTION_NONE {
+		return "ONIG_OPTION_NONE"
+	}
+	names := make([]string, 0, len(optionNames))
+	for _, o := range optionNames {
+		if option&o.option != 0 {
+			names = append(names, o.name)
+			option &^= o.option
+		}
+	}
+	if option != 0 {
+		names = append(names, fmt.Sprintf("Option(%#x)", int(option)))
+	}
+	return strings.Join(names, "|")
+}
+
+// check reports options that cannot be used to compile a pattern.
+func (option Option) check() error {
+	if invalid := option &^ compileTimeOptions; invalid != 0 {
+		return fmt.Errorf("rubex: %s cannot be used when compiling", invalid)
+	}
+	return nil
+}
+
+// Options returns the options the pattern was compiled with.
+func (re *Regexp) Options() Option {
+	return re.option
+}
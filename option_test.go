@@ -0,0 +1,61 @@
+package rubex
+
+import (
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	valid := map[string]Option{
+		"":    ONIG_OPTION_NONE,
+		"i":   ONIG_OPTION_IGNORECASE,
+		"imx": ONIG_OPTION_IGNORECASE | ONIG_OPTION_MULTILINE | ONIG_OPTION_EXTEND,
+		"xi":  ONIG_OPTION_IGNORECASE | ONIG_OPTION_EXTEND,
+		"mm":  ONIG_OPTION_MULTILINE,
+	}
+	for flags, expected := range valid {
+		option, err := ParseOptions(flags)
+		if err != nil || option != expected {
+			t.Errorf("ParseOptions(%q) = %s, %v; want %s", flags, option, err, expected)
+		}
+	}
+	for _, flags := range []string{"g", "iu", "I", " i"} {
+		if _, err := ParseOptions(flags); err == nil {
+			t.Errorf("ParseOptions(%q): expected an error", flags)
+		}
+	}
+}
+
+func TestOptionString(t *testing.T) {
+	option := Option(ONIG_OPTION_EXTEND | ONIG_OPTION_IGNORECASE | ONIG_OPTION_FIND_LONGEST)
+	if s := option.Flags(); s != "ix" {
+		t.Errorf("Flags() = %q", s)
+	}
+	if s := option.String(); s != "ONIG_OPTION_IGNORECASE|ONIG_OPTION_EXTEND|ONIG_OPTION_FIND_LONGEST" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := Option(ONIG_OPTION_NONE).String(); s != "ONIG_OPTION_NONE" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := Option(1 << 20).String(); s != "Option(0x100000)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestCompileOptions(t *testing.T) {
+	option, _ := ParseOptions("mi")
+	re := MustCompileWithOption("a.b", option)
+	if re.Options() != option {
+		t.Errorf("Options() = %s; want %s", re.Options(), option)
+	}
+	if !re.MatchString("A\nB") {
+		t.Errorf("options were not applied")
+	}
+	if MustCompile("a").Options() != ONIG_OPTION_NONE {
+		t.Errorf("expected no options")
+	}
+	for _, option := range []Option{ONIG_OPTION_NOTBOL, ONIG_OPTION_NOTEOL, ONIG_OPTION_POSIX_REGION, ONIG_OPTION_IGNORECASE | ONIG_OPTION_NOTBOL, 1 << 20} {
+		if _, err := CompileWithOption("a", option); err == nil {
+			t.Errorf("%s: expected a compile error", option)
+		}
+	}
+}
@@ -0,0 +1,918 @@
+package rubex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file parses Ruby-syntax patterns into a syntax tree, so that patterns
+// can be inspected and translated without the C library. It follows the
+// dialect of ONIG_SYNTAX_RUBY on UTF-8 text.
+
+type nodeOp int
+
+const (
+	opEmpty          nodeOp = iota
+	opLiteral               // runes
+	opClass                 // class
+	opAnyChar               // any rune but \n, or any rune if dotall
+	opBeginLine             // ^
+	opEndLine               // $
+	opBeginText             // \A
+	opEndText               // \z
+	opEndTextNewline        // \Z
+	opWordBoundary          // \b
+	opNoWordBoundary        // \B
+	opSearchStart           // \G
+	opConcat                // subs
+	opAlternate             // subs
+	opCapture               // subs[0], index, name
+	opGroup                 // subs[0]; non-capturing
+	opRepeat                // subs[0], min, max, lazy, possessive
+	opLookahead             // subs[0]
+	opNegLookahead          // subs[0]
+	opLookbehind            // subs[0]
+	opNegLookbehind         // subs[0]
+	opAtomic                // subs[0]
+	opBackref               // refs
+	opCall                  // refs, name
+	opUnsupported           // name describes the construct
+)
+
+// A node is one element of a parsed pattern. pos and end are the byte
+// offsets of the text it was parsed from.
+type node struct {
+	op       nodeOp
+	pos, end int
+
+	runes  []rune
+	class  *charClass
+	fold   bool
+	dotall bool
+
+	subs       []*node
+	min, max   int
+	lazy       bool
+	possessive bool
+
+	index int
+	name  string
+	refs  []int
+}
+
+// A parsedPattern is the syntax tree of a pattern together with its groups.
+type parsedPattern struct {
+	root        *node
+	numCaptures int
+	names       map[string][]int
+}
+
+// A parseError is a syntax error at a byte offset in the pattern.
+type parseError struct {
+	pos int
+	msg string
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("rubex: %s at offset %d", e.msg, e.pos)
+}
+
+type parseFlags struct {
+	ignoreCase bool
+	dotall     bool
+	extend     bool
+}
+
+type parser struct {
+	src    string
+	pos    int
+	option Option
+	flags  parseFlags
+
+	captures []*node
+	hasNames bool
+	backrefs []*node
+}
+
+// parsePattern parses a Ruby-syntax pattern compiled with the given options.
+func parsePattern(pattern string, option Option) (*parsedPattern, error) {
+	p := &parser{src: pattern, option: option}
+	p.flags = parseFlags{
+		ignoreCase: option&ONIG_OPTION_IGNORECASE != 0,
+		dotall:     option&ONIG_OPTION_MULTILINE != 0,
+		extend:     option&ONIG_OPTION_EXTEND != 0,
+	}
+	root, err := p.parseAlternation()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, &parseError{p.pos, "unmatched close parenthesis"}
+	}
+	return p.finish(root)
+}
+
+// finish numbers the groups. With named groups in the pattern, Ruby syntax
+// only captures the named ones, unless ONIG_OPTION_CAPTURE_GROUP is given.
+func (p *parser) finish(root *node) (*parsedPattern, error) {
+	parsed := &parsedPattern{root: root, names: make(map[string][]int)}
+	captureUnnamed := p.option&ONIG_OPTION_DONT_CAPTURE_GROUP == 0 &&
+		(!p.hasNames || p.option&ONIG_OPTION_CAPTURE_GROUP != 0)
+	for _, n := range p.captures {
+		if n.name == "" && !captureUnnamed {
+			n.op = opGroup
+			continue
+		}
+		parsed.numCaptures++
+		n.index = parsed.numCaptures
+		if n.name != "" {
+			parsed.names[n.name] = append(parsed.names[n.name], n.index)
+		}
+	}
+	for _, n := range p.backrefs {
+		if n.name != "" {
+			if n.refs = parsed.names[n.name]; n.refs == nil {
+				return nil, &parseError{n.pos, "undefined name <" + n.name + "> reference"}
+			}
+			continue
+		}
+		if p.hasNames && p.option&ONIG_OPTION_CAPTURE_GROUP == 0 {
+			return nil, &parseError{n.pos, "numbered backref/call is not allowed. (use name)"}
+		}
+		for i, ref := range n.refs {
+			if ref < 0 {
+				// relative references count the groups opened before them
+				ref = n.index + ref + 1
+				n.refs[i] = ref
+			}
+			if ref <= 0 || ref > parsed.numCaptures {
+				return nil, &parseError{n.pos, "invalid backref number/name"}
+			}
+		}
+	}
+	return parsed, nil
+}
+
+func (p *parser) more() bool {
+	return p.pos < len(p.src)
+}
+
+func (p *parser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *parser) lookingAt(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// skipExtended skips whitespace and comments in extended mode.
+func (p *parser) skipExtended() {
+	for p.flags.extend && p.more() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			p.pos++
+		case c == '#':
+			for p.more() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseAlternation() (*node, error) {
+	start := p.pos
+	var alts []*node
+	for {
+		concat, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, concat)
+		if !p.more() || p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &node{op: opAlternate, pos: start, end: p.pos, subs: alts}, nil
+}
+
+func (p *parser) parseConcat() (*node, error) {
+	start := p.pos
+	var items []*node
+	for {
+		p.skipExtended()
+		if !p.more() || p.peek() == '|' || p.peek() == ')' {
+			break
+		}
+		// an option switch such as (?i) applies to the rest of the group,
+		// alternatives included
+		if flags, ok, err := p.parseOptionSwitch(); err != nil {
+			return nil, err
+		} else if ok {
+			saved := p.flags
+			p.flags = flags
+			rest, err := p.parseAlternation()
+			p.flags = saved
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, rest)
+			break
+		}
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if atom == nil {
+			continue
+		}
+		if atom, err = p.parseQuantifiers(atom); err != nil {
+			return nil, err
+		}
+		if k := len(items); k > 0 && atom.op == opLiteral && items[k-1].op == opLiteral && items[k-1].fold == atom.fold {
+			items[k-1].runes = append(items[k-1].runes, atom.runes...)
+			items[k-1].end = atom.end
+			continue
+		}
+		items = append(items, atom)
+	}
+	switch len(items) {
+	case 0:
+		return &node{op: opEmpty, pos: start, end: p.pos}, nil
+	case 1:
+		return items[0], nil
+	}
+	return &node{op: opConcat, pos: start, end: p.pos, subs: items}, nil
+}
+
+// parseOptionSwitch parses (?imx-imx) that is not followed by a colon.
+func (p *parser) parseOptionSwitch() (parseFlags, bool, error) {
+	if !p.lookingAt("(?") {
+		return p.flags, false, nil
+	}
+	i := p.pos + 2
+	for i < len(p.src) && strings.IndexByte("imx-", p.src[i]) >= 0 {
+		i++
+	}
+	if i == p.pos+2 || i >= len(p.src) || p.src[i] != ')' {
+		return p.flags, false, nil
+	}
+	flags, err := p.applyOptions(p.src[p.pos+2:i], p.pos+2)
+	if err != nil {
+		return flags, false, err
+	}
+	p.pos = i + 1
+	return flags, true, nil
+}
+
+func (p *parser) applyOptions(spec string, pos int) (parseFlags, error) {
+	flags := p.flags
+	on := true
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '-':
+			if !on {
+				return flags, &parseError{pos + i, "invalid group option"}
+			}
+			on = false
+		case 'i':
+			flags.ignoreCase = on
+		case 'm':
+			flags.dotall = on
+		case 'x':
+			flags.extend = on
+		}
+	}
+	return flags, nil
+}
+
+func (p *parser) parseQuantifiers(atom *node) (*node, error) {
+	for {
+		p.skipExtended()
+		if !p.more() {
+			return atom, nil
+		}
+		start := p.pos
+		min, max := 0, -1
+		fixed := false
+		switch p.peek() {
+		case '*':
+			p.pos++
+		case '+':
+			min = 1
+			p.pos++
+		case '?':
+			max = 1
+			p.pos++
+		case '{':
+			var ok bool
+			if min, max, ok = p.parseInterval(); !ok {
+				return atom, nil
+			}
+			fixed = min == max
+		default:
+			return atom, nil
+		}
+		if !repeatable(atom) {
+			return nil, &parseError{start, "target of repeat operator is invalid"}
+		}
+		repeat := &node{op: opRepeat, pos: atom.pos, subs: []*node{atom}, min: min, max: max}
+		if p.more() && !fixed {
+			switch p.peek() {
+			case '?':
+				repeat.lazy = true
+				p.pos++
+			case '+':
+				// only the one-character operators have possessive forms
+				if p.src[start] != '{' {
+					repeat.possessive = true
+					p.pos++
+				}
+			}
+		}
+		repeat.end = p.pos
+		atom = repeat
+	}
+}
+
+func repeatable(n *node) bool {
+	switch n.op {
+	case opBeginLine, opEndLine, opBeginText, opEndText, opEndTextNewline,
+		opWordBoundary, opNoWordBoundary, opSearchStart:
+		return false
+	}
+	return true
+}
+
+// parseInterval parses {n}, {n,}, {,m} or {n,m}. Anything else is a literal
+// brace, and ok is false.
+func (p *parser) parseInterval() (min, max int, ok bool) {
+	end := strings.IndexByte(p.src[p.pos:], '}')
+	if end < 0 {
+		return 0, 0, false
+	}
+	body := p.src[p.pos+1 : p.pos+end]
+	lo, hi, comma := body, body, false
+	if i := strings.IndexByte(body, ','); i >= 0 {
+		lo, hi, comma = body[:i], body[i+1:], true
+	}
+	if lo == "" && (!comma || hi == "") {
+		return 0, 0, false
+	}
+	parse := func(s string, empty int) (int, bool) {
+		if s == "" {
+			return empty, true
+		}
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return 0, false
+			}
+		}
+		n, err := strconv.Atoi(s)
+		return n, err == nil && n <= 100000
+	}
+	var okLo, okHi bool
+	if min, okLo = parse(lo, 0); !okLo {
+		return 0, 0, false
+	}
+	if !comma {
+		max, okHi = min, true
+	} else {
+		max, okHi = parse(hi, -1)
+	}
+	if !okHi || max >= 0 && max < min {
+		return 0, 0, false
+	}
+	p.pos += end + 1
+	return min, max, true
+}
+
+// parseAtom parses one element of a concatenation. It returns nil for a
+// comment.
+func (p *parser) parseAtom() (*node, error) {
+	start := p.pos
+	c := p.peek()
+	switch c {
+	case '(':
+		return p.parseGroup()
+	case '[':
+		class, err := p.parseClass()
+		if err != nil {
+			return nil, err
+		}
+		return &node{op: opClass, pos: start, end: p.pos, class: class, fold: p.flags.ignoreCase}, nil
+	case '.':
+		p.pos++
+		return &node{op: opAnyChar, pos: start, end: p.pos, dotall: p.flags.dotall}, nil
+	case '^':
+		p.pos++
+		if p.option&ONIG_OPTION_SINGLELINE != 0 {
+			return &node{op: opBeginText, pos: start, end: p.pos}, nil
+		}
+		return &node{op: opBeginLine, pos: start, end: p.pos}, nil
+	case '$':
+		p.pos++
+		if p.option&ONIG_OPTION_SINGLELINE != 0 {
+			return &node{op: opEndTextNewline, pos: start, end: p.pos}, nil
+		}
+		return &node{op: opEndLine, pos: start, end: p.pos}, nil
+	case '\\':
+		return p.parseEscape()
+	case '*', '+', '?':
+		return nil, &parseError{start, "target of repeat operator is not specified"}
+	case '{':
+		if _, _, ok := p.parseInterval(); ok {
+			return nil, &parseError{start, "target of repeat operator is not specified"}
+		}
+	}
+	r, width := utf8.DecodeRuneInString(p.src[p.pos:])
+	if r == utf8.RuneError && width == 1 {
+		return nil, &parseError{start, "invalid code point value"}
+	}
+	p.pos += width
+	return p.literal(start, r), nil
+}
+
+func (p *parser) literal(start int, r rune) *node {
+	return &node{op: opLiteral, pos: start, end: p.pos, runes: []rune{r}, fold: p.flags.ignoreCase}
+}
+
+func (p *parser) parseGroup() (*node, error) {
+	start := p.pos
+	p.pos++
+	n := &node{op: opGroup, pos: start}
+	saved := p.flags
+	defer func() { p.flags = saved }()
+	if p.lookingAt("?") {
+		p.pos++
+		if !p.more() {
+			return nil, &parseError{start, "end pattern in group"}
+		}
+		switch c := p.peek(); {
+		case c == '#':
+			end := strings.IndexByte(p.src[p.pos:], ')')
+			if end < 0 {
+				return nil, &parseError{start, "end pattern in group"}
+			}
+			p.pos += end + 1
+			return nil, nil
+		case c == ':':
+			p.pos++
+		case c == '=':
+			n.op = opLookahead
+			p.pos++
+		case c == '!':
+			n.op = opNegLookahead
+			p.pos++
+		case c == '>':
+			n.op = opAtomic
+			p.pos++
+		case c == '~':
+			n.op = opUnsupported
+			n.name = "absent operator"
+			p.pos++
+		case p.lookingAt("<=") || p.lookingAt("<!"):
+			n.op = opLookbehind
+			if c := p.src[p.pos+1]; c == '!' {
+				n.op = opNegLookbehind
+			}
+			p.pos += 2
+		case c == '<' || c == '\'':
+			name, err := p.parseGroupName(c)
+			if err != nil {
+				return nil, err
+			}
+			n.op = opCapture
+			n.name = name
+			p.hasNames = true
+		case c == '(':
+			return nil, &parseError{p.pos, "conditional groups are not supported"}
+		default:
+			i := p.pos
+			for i < len(p.src) && strings.IndexByte("imx-", p.src[i]) >= 0 {
+				i++
+			}
+			if i == p.pos || i >= len(p.src) || p.src[i] != ':' {
+				return nil, &parseError{p.pos, "undefined group option"}
+			}
+			flags, err := p.applyOptions(p.src[p.pos:i], p.pos)
+			if err != nil {
+				return nil, err
+			}
+			p.flags = flags
+			p.pos = i + 1
+		}
+	} else {
+		n.op = opCapture
+	}
+	if n.op == opCapture {
+		p.captures = append(p.captures, n)
+	}
+	sub, err := p.parseAlternation()
+	if err != nil {
+		return nil, err
+	}
+	if !p.more() || p.peek() != ')' {
+		return nil, &parseError{start, "end pattern with unmatched parenthesis"}
+	}
+	p.pos++
+	n.subs = []*node{sub}
+	n.end = p.pos
+	return n, nil
+}
+
+// parseGroupName parses <name> or 'name', starting at the opening delimiter.
+func (p *parser) parseGroupName(open byte) (string, error) {
+	close := byte('>')
+	if open == '\'' {
+		close = '\''
+	}
+	start := p.pos
+	end := strings.IndexByte(p.src[p.pos+1:], close)
+	if end < 0 {
+		return "", &parseError{start, "invalid group name"}
+	}
+	name := p.src[p.pos+1 : p.pos+1+end]
+	if !validGroupName(name) {
+		return "", &parseError{start, "invalid group name <" + name + ">"}
+	}
+	p.pos += end + 2
+	return name, nil
+}
+
+func validGroupName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= utf8.RuneSelf) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapes that stand for a single character, in and out of classes
+var controlEscapes = map[byte]rune{
+	't': '\t', 'n': '\n', 'r': '\r', 'f': '\f', 'v': '\v', 'a': '\a', 'e': 0x1b,
+}
+
+func (p *parser) parseEscape() (*node, error) {
+	start := p.pos
+	p.pos++
+	if !p.more() {
+		return nil, &parseError{start, "end pattern at escape"}
+	}
+	c := p.peek()
+	n := &node{pos: start}
+	switch c {
+	case 'A':
+		n.op = opBeginText
+	case 'z':
+		n.op = opEndText
+	case 'Z':
+		n.op = opEndTextNewline
+	case 'b':
+		n.op = opWordBoundary
+	case 'B':
+		n.op = opNoWordBoundary
+	case 'G':
+		n.op = opSearchStart
+	case 'K', 'R', 'X', 'N', 'O', 'y', 'Y':
+		n.op = opUnsupported
+		n.name = `\` + string(c)
+	case 'k':
+		return p.parseNamedReference(opBackref)
+	case 'g':
+		return p.parseNamedReference(opCall)
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if ref, ok := p.parseDecimalBackref(); ok {
+			n.op = opBackref
+			n.refs = []int{ref}
+			n.fold = p.flags.ignoreCase
+			n.end = p.pos
+			p.backrefs = append(p.backrefs, n)
+			return n, nil
+		}
+		fallthrough
+	default:
+		if class, ok, err := p.parseClassEscape(); err != nil {
+			return nil, err
+		} else if ok {
+			return &node{op: opClass, pos: start, end: p.pos, class: class, fold: p.flags.ignoreCase}, nil
+		}
+		p.pos = start + 1
+		r, err := p.parseCharEscape(false)
+		if err != nil {
+			return nil, err
+		}
+		return p.literal(start, r), nil
+	}
+	p.pos++
+	n.end = p.pos
+	return n, nil
+}
+
+// parseDecimalBackref parses \n. A number up to 9, or up to the number of
+// groups opened so far, is a backreference; anything else is an octal escape.
+func (p *parser) parseDecimalBackref() (int, bool) {
+	end := p.pos
+	for end < len(p.src) && p.src[end] >= '0' && p.src[end] <= '9' {
+		end++
+	}
+	num, err := strconv.Atoi(p.src[p.pos:end])
+	if err != nil || num > 9 && num > len(p.captures) {
+		return 0, false
+	}
+	p.pos = end
+	return num, true
+}
+
+// parseNamedReference parses \k<name> or \g<name>, where the name may also
+// be a group number, relative with a sign.
+func (p *parser) parseNamedReference(op nodeOp) (*node, error) {
+	start := p.pos - 1
+	p.pos++
+	if !p.more() || (p.peek() != '<' && p.peek() != '\'') {
+		if op == opBackref {
+			return nil, &parseError{start, "invalid backref number/name"}
+		}
+		return p.literal(start, 'g'), nil
+	}
+	close := byte('>')
+	if p.peek() == '\'' {
+		close = '\''
+	}
+	end := strings.IndexByte(p.src[p.pos+1:], close)
+	if end < 0 {
+		return nil, &parseError{start, "invalid group name"}
+	}
+	name := p.src[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	n := &node{op: op, pos: start, end: p.pos, fold: p.flags.ignoreCase, index: len(p.captures)}
+	if num, err := strconv.Atoi(name); err == nil {
+		if name[0] == '-' || name[0] == '+' {
+			if num >= 0 {
+				return nil, &parseError{start, "invalid backref number/name"}
+			}
+		} else if num < 0 {
+			return nil, &parseError{start, "invalid backref number/name"}
+		}
+		n.refs = []int{num}
+	} else if validGroupName(name) {
+		n.name = name
+	} else {
+		return nil, &parseError{start, "invalid group name <" + name + ">"}
+	}
+	if op == opBackref {
+		p.backrefs = append(p.backrefs, n)
+	}
+	return n, nil
+}
+
+// parseClassEscape parses an escape that stands for a set, with p.pos at the
+// character after the backslash.
+func (p *parser) parseClassEscape() (*charClass, bool, error) {
+	var prop *charProperty
+	negate := false
+	switch c := p.peek(); c {
+	case 'd', 'D':
+		prop, negate = propDigit, c == 'D'
+	case 's', 'S':
+		prop, negate = propSpace, c == 'S'
+	case 'w', 'W':
+		prop, negate = propWord, c == 'W'
+	case 'h', 'H':
+		prop, negate = propXDigit, c == 'H'
+	case 'p', 'P':
+		start := p.pos - 1
+		if !p.lookingAt(string(c) + "{") {
+			return nil, false, &parseError{start, "invalid character property name"}
+		}
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, false, &parseError{start, "invalid character property name"}
+		}
+		name := p.src[p.pos+2 : p.pos+end]
+		negate = c == 'P'
+		if strings.HasPrefix(name, "^") {
+			name, negate = name[1:], !negate
+		}
+		if prop = lookupProperty(name); prop == nil {
+			return nil, false, &parseError{start, "invalid character property name {" + name + "}"}
+		}
+		p.pos += end + 1
+		return propertyClass(prop, negate), true, nil
+	default:
+		return nil, false, nil
+	}
+	p.pos++
+	return propertyClass(prop, negate), true, nil
+}
+
+// parseCharEscape parses an escape that stands for one character, with p.pos
+// at the character after the backslash.
+func (p *parser) parseCharEscape(inClass bool) (rune, error) {
+	start := p.pos - 1
+	c := p.peek()
+	if r, ok := controlEscapes[c]; ok {
+		p.pos++
+		return r, nil
+	}
+	switch c {
+	case 'b':
+		if inClass {
+			p.pos++
+			return '\b', nil
+		}
+	case 'x':
+		p.pos++
+		if p.lookingAt("{") {
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 {
+				return 0, &parseError{start, "invalid code point value"}
+			}
+			r, err := strconv.ParseUint(p.src[p.pos+1:p.pos+end], 16, 32)
+			if err != nil || r > utf8.MaxRune {
+				return 0, &parseError{start, "invalid code point value"}
+			}
+			p.pos += end + 1
+			return rune(r), nil
+		}
+		return p.parseDigits(16, 2, start)
+	case 'u':
+		p.pos++
+		if len(p.src)-p.pos < 4 {
+			return 0, &parseError{start, "too short digits"}
+		}
+		r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+		if err != nil {
+			return 0, &parseError{start, "invalid Unicode escape"}
+		}
+		p.pos += 4
+		return rune(r), nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		return p.parseDigits(8, 3, start)
+	case 'c':
+		p.pos++
+		if !p.more() || p.peek() >= utf8.RuneSelf {
+			return 0, &parseError{start, "end pattern at control"}
+		}
+		r := rune(p.peek() & 0x1f)
+		p.pos++
+		return r, nil
+	case 'C', 'M':
+		return 0, &parseError{start, `\` + string(c) + "- escapes are not supported"}
+	}
+	r, width := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += width
+	return r, nil
+}
+
+// parseDigits parses up to max digits in the given base. Escapes that are
+// too large for a byte are not valid UTF-8 and are rejected.
+func (p *parser) parseDigits(base int, max int, start int) (rune, error) {
+	end := p.pos
+	for end < len(p.src) && end-p.pos < max && isDigitIn(p.src[end], base) {
+		end++
+	}
+	if end == p.pos {
+		return 0, &parseError{start, "invalid code point value"}
+	}
+	r, _ := strconv.ParseUint(p.src[p.pos:end], base, 32)
+	if r >= utf8.RuneSelf {
+		return 0, &parseError{start, "invalid code point value"}
+	}
+	p.pos = end
+	return rune(r), nil
+}
+
+func isDigitIn(c byte, base int) bool {
+	switch {
+	case c >= '0' && c <= '7':
+		return true
+	case c == '8' || c == '9':
+		return base > 8
+	case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+		return base == 16
+	}
+	return false
+}
+
+// parseClass parses a bracket expression, with p.pos at the opening bracket.
+func (p *parser) parseClass() (*charClass, error) {
+	start := p.pos
+	p.pos++
+	class := &charClass{}
+	if p.lookingAt("^") {
+		class.negate = true
+		p.pos++
+	}
+	current := class
+	first := true
+	for {
+		if !p.more() {
+			return nil, &parseError{start, "premature end of char-class"}
+		}
+		c := p.peek()
+		switch {
+		case c == ']' && !first:
+			p.pos++
+			return class, nil
+		case p.lookingAt("&&"):
+			p.pos += 2
+			operand := &charClass{}
+			class.and = append(class.and, operand)
+			current = operand
+			first = false
+			continue
+		case p.lookingAt("[:"):
+			if prop, negate, ok := p.parsePosixBracket(); ok {
+				current.items = append(current.items, classItem{prop: prop, negate: negate})
+				first = false
+				continue
+			}
+		case c == '[':
+			nested, err := p.parseClass()
+			if err != nil {
+				return nil, err
+			}
+			current.items = append(current.items, classItem{class: nested})
+			first = false
+			continue
+		}
+		first = false
+		lo, set, err := p.parseClassAtom()
+		if err != nil {
+			return nil, err
+		}
+		if set != nil {
+			current.items = append(current.items, classItem{class: set})
+			continue
+		}
+		if p.lookingAt("-") && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			rangePos := p.pos
+			p.pos++
+			hi, hiSet, err := p.parseClassAtom()
+			if err != nil {
+				return nil, err
+			}
+			if hiSet != nil {
+				return nil, &parseError{rangePos, "char-class value at end of range"}
+			}
+			if hi < lo {
+				return nil, &parseError{rangePos, "empty range in char class"}
+			}
+			current.items = append(current.items, classItem{lo: lo, hi: hi})
+			continue
+		}
+		current.items = append(current.items, classItem{lo: lo, hi: lo})
+	}
+}
+
+// parseClassAtom parses a character or a set escape inside a class.
+func (p *parser) parseClassAtom() (rune, *charClass, error) {
+	if p.peek() != '\\' {
+		r, width := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r == utf8.RuneError && width == 1 {
+			return 0, nil, &parseError{p.pos, "invalid code point value"}
+		}
+		p.pos += width
+		return r, nil, nil
+	}
+	p.pos++
+	if !p.more() {
+		return 0, nil, &parseError{p.pos - 1, "end pattern at escape"}
+	}
+	if class, ok, err := p.parseClassEscape(); err != nil || ok {
+		return 0, class, err
+	}
+	r, err := p.parseCharEscape(true)
+	return r, nil, err
+}
+
+// parsePosixBracket parses [:name:] or [:^name:].
+func (p *parser) parsePosixBracket() (*charProperty, bool, bool) {
+	end := strings.Index(p.src[p.pos:], ":]")
+	if end < 0 {
+		return nil, false, false
+	}
+	name := p.src[p.pos+2 : p.pos+end]
+	negate := strings.HasPrefix(name, "^")
+	if negate {
+		name = name[1:]
+	}
+	prop, ok := posixProperties[name]
+	if !ok {
+		return nil, false, false
+	}
+	p.pos += end + 2
+	return prop, negate, true
+}
@@ -0,0 +1,82 @@
+package rubex
+
+import (
+	"unicode"
+)
+
+// LiteralPrefix returns a literal string that must begin any match of the
+// regular expression re. It returns the boolean true if the literal string
+// comprises the entire regular expression.
+//
+// Case-insensitive letters end the prefix, and so does anything that can
+// match more than one string, such as a class or an alternation whose
+// alternatives do not share a prefix. Only Ruby-syntax patterns on UTF-8 text
+// are analyzed; for others the prefix is always empty.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	if re.syntax != ONIG_SYNTAX_RUBY || re.encoding != ONIG_ENCODING_UTF8 {
+		return "", false
+	}
+	parsed, err := parsePattern(re.pattern, re.option)
+	if err != nil {
+		return "", false
+	}
+	runes, complete := literalPrefix(parsed.root)
+	return string(runes), complete
+}
+
+// literalPrefix returns the runes every match of n starts with, and whether n
+// matches nothing but them.
+func literalPrefix(n *node) (prefix []rune, complete bool) {
+	switch n.op {
+	case opEmpty:
+		return nil, true
+	case opLiteral:
+		for _, r := range n.runes {
+			if n.fold && !foldsToItself(r) {
+				return prefix, false
+			}
+			prefix = append(prefix, r)
+		}
+		return prefix, true
+	case opConcat:
+		for _, sub := range n.subs {
+			subPrefix, subComplete := literalPrefix(sub)
+			prefix = append(prefix, subPrefix...)
+			if !subComplete {
+				return prefix, false
+			}
+		}
+		return prefix, true
+	case opCapture, opGroup:
+		return literalPrefix(n.subs[0])
+	case opAtomic:
+		prefix, _ = literalPrefix(n.subs[0])
+		return prefix, false
+	case opRepeat:
+		if n.min == 0 {
+			return nil, false
+		}
+		prefix, _ = literalPrefix(n.subs[0])
+		return prefix, false
+	case opAlternate:
+		// the alternatives share a prefix, unless one of them matches more
+		// after it than the others
+		prefix, complete = literalPrefix(n.subs[0])
+		for _, sub := range n.subs[1:] {
+			subPrefix, subComplete := literalPrefix(sub)
+			common := 0
+			for common < len(prefix) && common < len(subPrefix) && prefix[common] == subPrefix[common] {
+				common++
+			}
+			complete = complete && subComplete && common == len(prefix) && common == len(subPrefix)
+			prefix = prefix[:common]
+		}
+		return prefix, complete
+	}
+	return nil, false
+}
+
+// foldsToItself reports whether r matches only itself when case is ignored.
+func foldsToItself(r rune) bool {
+	return r <= unicode.MaxASCII && !unicode.IsLetter(r)
+}
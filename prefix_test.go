@@ -0,0 +1,53 @@
+package rubex
+
+import (
+	"testing"
+)
+
+var literalPrefixTests = []struct {
+	pattern  string
+	option   Option
+	prefix   string
+	complete bool
+}{
+	{`abc`, ONIG_OPTION_NONE, "abc", true},
+	{`ab+c`, ONIG_OPTION_NONE, "ab", false},
+	{`ab*c`, ONIG_OPTION_NONE, "a", false},
+	{`a(bc)d`, ONIG_OPTION_NONE, "abcd", true},
+	{`a(?:bc)?d`, ONIG_OPTION_NONE, "a", false},
+	{`a(?>bc)d`, ONIG_OPTION_NONE, "abc", false},
+	{`\x41é\n\(`, ONIG_OPTION_NONE, "Aé\n(", true},
+	{`héllo.`, ONIG_OPTION_NONE, "héllo", false},
+	{`abc|abd`, ONIG_OPTION_NONE, "ab", false},
+	{`foo|foobar`, ONIG_OPTION_NONE, "foo", false},
+	{`abc|xyz`, ONIG_OPTION_NONE, "", false},
+	{`x(abc|abd)`, ONIG_OPTION_NONE, "xab", false},
+	{`ab|ab`, ONIG_OPTION_NONE, "ab", true},
+	{`abc`, ONIG_OPTION_IGNORECASE, "", false},
+	{`12a`, ONIG_OPTION_IGNORECASE, "12", false},
+	{`ab(?i)c`, ONIG_OPTION_NONE, "ab", false},
+	{`a(?i:b)c`, ONIG_OPTION_NONE, "a", false},
+	{`(?i)a(?-i)bc`, ONIG_OPTION_NONE, "", false},
+	{`a b # comment`, ONIG_OPTION_EXTEND, "ab", true},
+	{`(?x) a \  b`, ONIG_OPTION_NONE, "a b", true},
+	{`a b`, ONIG_OPTION_NONE, "a b", true},
+	{`^abc`, ONIG_OPTION_NONE, "", false},
+	{`abc$`, ONIG_OPTION_NONE, "abc", false},
+	{`a[b]`, ONIG_OPTION_NONE, "a", false},
+	{`a(?=b)`, ONIG_OPTION_NONE, "a", false},
+}
+
+func TestLiteralPrefixAnalysis(t *testing.T) {
+	for _, test := range literalPrefixTests {
+		re := MustCompileWithOption(test.pattern, test.option)
+		prefix, complete := re.LiteralPrefix()
+		if prefix != test.prefix || complete != test.complete {
+			t.Errorf("%q.LiteralPrefix() = %q, %v; want %q, %v", test.pattern, prefix, complete, test.prefix, test.complete)
+		}
+	}
+	if re, err := CompileWithSyntax(`abc`, ONIG_OPTION_NONE, ONIG_SYNTAX_PERL); err == nil {
+		if prefix, complete := re.LiteralPrefix(); prefix != "" || complete {
+			t.Errorf("a Perl pattern has the literal prefix %q, %v", prefix, complete)
+		}
+	}
+}
@@ -1,71 +1,114 @@
 package rubex
 
-/*
-#cgo CFLAGS: -I${SRCDIR}/../../../../../clibs/include
-#cgo LDFLAGS: -L${SRCDIR}/../../../../../clibs/lib -lonig
-#include <stdlib.h>
-#include <oniguruma.h>
-#include "chelper.h"
-*/
-import "C"
-
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	//"runtime"
+	"regexp"
+	"runtime"
 	"strconv"
-	"sync"
-	"unicode/utf8"
-	"unsafe"
+	"strings"
+	"sync/atomic"
 )
 
 type strRange []int
 
 const numMatchStartSize = 4
-const numReadBufferStartSize = 256
 
-var mutex sync.Mutex
+// ErrFreed is the panic value of any search on a Regexp after Free or Close,
+// and the error returned by a second Close.
+var ErrFreed = errors.New("rubex: use of freed Regexp")
+
+// A SearchError is the panic value of a search that was given up on instead
+// of finished, because it went over Oniguruma's retry limit or the nesting
+// limit of the pure-Go backtracker, ran out of memory or was given invalid
+// arguments. Code is Oniguruma's error code.
+type SearchError struct {
+	Code    int
+	Message string
+}
 
-type MatchData struct {
-	count   int
-	indexes [][]int32
+func (e *SearchError) Error() string {
+	return "rubex: search failed: " + e.Message
 }
 
+// liveRegexps counts the native regexes that have not been released yet.
+var liveRegexps int64
+
 type NamedGroupInfo map[string]int
 
+// A Regexp is a compiled Oniguruma pattern, or in builds without cgo a pattern
+// for the pure-Go backtracker. The native regex is read-only once compiled;
+// every search allocates its own OnigRegion and capture buffer, so a single
+// Regexp can be used by many goroutines at once.
+//
+// The native regex is released by a finalizer once the Regexp is unreachable,
+// or earlier by Free or Close. refs counts the owner's reference plus one per
+// search in flight, so an explicit Free never pulls the regex out from under a
+// running search; it is released when the last search returns.
+//
+// Every search method panics with ErrFreed once the Regexp has been freed,
+// and with a *SearchError when the search cannot be finished.
 type Regexp struct {
 	pattern        string
-	regex          C.OnigRegex
-	region         *C.OnigRegion
-	errorInfo      *C.OnigErrorInfo
-	errorBuf       *C.char
-	matchData      *MatchData
+	option         Option
+	syntax         Syntax
+	encoding       Encoding
+	regex          nativeRegex
+	refs           int32
+	freed          int32
+	numCaptures    int
 	namedGroupInfo NamedGroupInfo
-}
-
-func NewRegexp(pattern string, option int) (re *Regexp, err error) {
-	re = &Regexp{pattern: pattern}
-	patternCharPtr := C.CString(pattern)
-	defer C.free(unsafe.Pointer(patternCharPtr))
-
-	mutex.Lock()
-	defer mutex.Unlock()
-	error_code := C.NewOnigRegex(patternCharPtr, C.int(len(pattern)), C.int(option), &re.regex, &re.region, &re.errorInfo, &re.errorBuf)
-	if error_code != C.ONIG_NORMAL {
-		err = errors.New(C.GoString(re.errorBuf))
-	} else {
-		err = nil
-		numCapturesInPattern := int(C.onig_number_of_captures(re.regex)) + 1
-		re.matchData = &MatchData{}
-		re.matchData.indexes = make([][]int32, numMatchStartSize)
-		for i := 0; i < numMatchStartSize; i++ {
-			re.matchData.indexes[i] = make([]int32, numCapturesInPattern*2)
+	// subexpNames holds the name of each group, "" when it has none
+	subexpNames []string
+	// goEmptyMatches makes findAll drop empty matches that directly follow
+	// the previous match, as Go's regexp does.
+	goEmptyMatches bool
+	// implied holds what the constructor added to option, which Options
+	// leaves out; see CompileBinary
+	implied Option
+	// longest finds leftmost-longest matches; see Longest
+	longest *backtracker
+	// goRegexp is set when searches run on Go's regexp; see SetGoBackend
+	goRegexp       *regexp.Regexp
+	goMatchesEmpty bool
+	goLeftContext  bool
+	// owner holds the native regex when this Regexp was filled in by
+	// UnmarshalText, which cannot give it a finalizer of its own.
+	owner *Regexp
+}
+
+func NewRegexp(pattern string, option Option) (re *Regexp, err error) {
+	return NewRegexpWithSyntax(pattern, option, ONIG_SYNTAX_DEFAULT)
+}
+
+// NewRegexpWithSyntax compiles pattern as written for the given syntax.
+func NewRegexpWithSyntax(pattern string, option Option, syntax Syntax) (re *Regexp, err error) {
+	return NewRegexpWithEncoding(pattern, option, syntax, ONIG_ENCODING_DEFAULT)
+}
+
+// NewRegexpWithEncoding compiles pattern as written for the given syntax, for
+// searching text in the given encoding. The pattern itself must be in that
+// encoding too.
+func NewRegexpWithEncoding(pattern string, option Option, syntax Syntax, encoding Encoding) (re *Regexp, err error) {
+	return newRegexp(pattern, option, ONIG_OPTION_NONE, syntax, encoding)
+}
+
+func newRegexp(pattern string, option Option, implied Option, syntax Syntax, encoding Encoding) (re *Regexp, err error) {
+	re = &Regexp{pattern: pattern, option: option, implied: implied, syntax: syntax, encoding: encoding}
+	if err = option.check(); err != nil {
+		return re, err
+	}
+	if err = re.compile(); err == nil {
+		re.refs = 1
+		atomic.AddInt64(&liveRegexps, 1)
+		runtime.SetFinalizer(re, (*Regexp).Free)
+		re.routeToGo()
+		if option&ONIG_OPTION_FIND_LONGEST != 0 {
+			if err = re.setLongest(); err != nil {
+				re.Free()
+			}
 		}
-		re.namedGroupInfo = re.getNamedGroupInfo()
-		//runtime.SetFinalizer(re, (*Regexp).Free)
 	}
 	return re, err
 }
@@ -82,11 +125,11 @@ func MustCompile(str string) *Regexp {
 	return regexp
 }
 
-func CompileWithOption(str string, option int) (*Regexp, error) {
+func CompileWithOption(str string, option Option) (*Regexp, error) {
 	return NewRegexp(str, option)
 }
 
-func MustCompileWithOption(str string, option int) *Regexp {
+func MustCompileWithOption(str string, option Option) *Regexp {
 	regexp, error := NewRegexp(str, option)
 	if error != nil {
 		panic("regexp: compiling " + str + ": " + error.Error())
@@ -94,53 +137,96 @@ func MustCompileWithOption(str string, option int) *Regexp {
 	return regexp
 }
 
+func CompileWithSyntax(str string, option Option, syntax Syntax) (*Regexp, error) {
+	return NewRegexpWithSyntax(str, option, syntax)
+}
+
+func MustCompileWithSyntax(str string, option Option, syntax Syntax) *Regexp {
+	regexp, error := NewRegexpWithSyntax(str, option, syntax)
+	if error != nil {
+		panic("regexp: compiling " + str + ": " + error.Error())
+	}
+	return regexp
+}
+
+func CompileWithEncoding(str string, option Option, encoding Encoding) (*Regexp, error) {
+	return NewRegexpWithEncoding(str, option, ONIG_SYNTAX_DEFAULT, encoding)
+}
+
+func MustCompileWithEncoding(str string, option Option, encoding Encoding) *Regexp {
+	regexp, error := NewRegexpWithEncoding(str, option, ONIG_SYNTAX_DEFAULT, encoding)
+	if error != nil {
+		panic("regexp: compiling " + str + ": " + error.Error())
+	}
+	return regexp
+}
+
+// CompileBinary compiles a pattern for raw binary data: every byte is one
+// character, \xHH escapes stand for single bytes, and . matches any byte,
+// newline included. Character classes such as \w and \s only match ASCII.
+//
+// The pattern is compiled with ONIG_OPTION_MULTILINE for the . to match a
+// newline, but Options reports no options; MarshalText writes the m flag out so
+// that the text compiles to the same matches.
+func CompileBinary(str string) (*Regexp, error) {
+	return newRegexp(str, ONIG_OPTION_NONE, ONIG_OPTION_MULTILINE, ONIG_SYNTAX_DEFAULT, ONIG_ENCODING_BINARY)
+}
+
+func MustCompileBinary(str string) *Regexp {
+	regexp, error := CompileBinary(str)
+	if error != nil {
+		panic("regexp: compiling " + str + ": " + error.Error())
+	}
+	return regexp
+}
+
+// Free releases the native regex. It is safe to call more than once, and
+// from several goroutines; searches still running finish normally, and any
+// search started afterwards panics with ErrFreed.
 func (re *Regexp) Free() {
-	mutex.Lock()
-	if re.regex != nil {
-		C.onig_free(re.regex)
-		re.regex = nil
+	re.Close()
+}
+
+// Close is like Free but reports ErrFreed if the Regexp was already freed.
+func (re *Regexp) Close() error {
+	if re.owner != nil {
+		return re.owner.Close()
 	}
-	if re.region != nil {
-		C.onig_region_free(re.region, 1)
-		re.region = nil
-	}
-	mutex.Unlock()
-	if re.errorInfo != nil {
-		C.free(unsafe.Pointer(re.errorInfo))
-		re.errorInfo = nil
-	}
-	if re.errorBuf != nil {
-		C.free(unsafe.Pointer(re.errorBuf))
-		re.errorBuf = nil
-	}
-}
-
-func (re *Regexp) getNamedGroupInfo() (namedGroupInfo NamedGroupInfo) {
-	numNamedGroups := int(C.onig_number_of_names(re.regex))
-	//when any named capture exisits, there is no numbered capture even if there are unnamed captures
-	if numNamedGroups > 0 {
-		namedGroupInfo = make(map[string]int)
-		//try to get the names
-		bufferSize := len(re.pattern) * 2
-		nameBuffer := make([]byte, bufferSize)
-		groupNumbers := make([]int32, numNamedGroups)
-		bufferPtr := unsafe.Pointer(&nameBuffer[0])
-		numbersPtr := unsafe.Pointer(&groupNumbers[0])
-		length := int(C.GetCaptureNames(re.regex, bufferPtr, (C.int)(bufferSize), (*C.int)(numbersPtr)))
-		if length > 0 {
-			namesAsBytes := bytes.Split(nameBuffer[:length], ([]byte)(";"))
-			if len(namesAsBytes) != numNamedGroups {
-				log.Fatalf("the number of named groups (%d) does not match the number names found (%d)\n", numNamedGroups, len(namesAsBytes))
-			}
-			for i, nameAsBytes := range namesAsBytes {
-				name := string(nameAsBytes)
-				namedGroupInfo[name] = int(groupNumbers[i])
-			}
-		} else {
-			log.Fatalf("could not get the capture group names from %q", re.String())
+	if !atomic.CompareAndSwapInt32(&re.freed, 0, 1) {
+		return ErrFreed
+	}
+	runtime.SetFinalizer(re, nil)
+	re.release()
+	return nil
+}
+
+// acquire takes a reference to the native regex for the duration of a search.
+// Every acquire must be paired with a release.
+func (re *Regexp) acquire() nativeRegex {
+	if re.owner != nil {
+		return re.owner.acquire()
+	}
+	for {
+		refs := atomic.LoadInt32(&re.refs)
+		if refs <= 0 {
+			panic(ErrFreed)
+		}
+		if atomic.CompareAndSwapInt32(&re.refs, refs, refs+1) {
+			return re.regex
 		}
 	}
-	return
+}
+
+func (re *Regexp) release() {
+	if re.owner != nil {
+		re.owner.release()
+		return
+	}
+	if atomic.AddInt32(&re.refs, -1) == 0 {
+		freeNativeRegex(re.regex)
+		re.regex = nil
+		atomic.AddInt64(&liveRegexps, -1)
+	}
 }
 
 func (re *Regexp) groupNameToId(name string) (id int) {
@@ -152,41 +238,29 @@ func (re *Regexp) groupNameToId(name string) (id int) {
 	return
 }
 
-func (re *Regexp) processMatch(numCaptures int) (match []int32) {
-	if numCaptures <= 0 {
-		panic("cannot have 0 captures when processing a match")
-	}
-	matchData := re.matchData
-	return matchData.indexes[matchData.count][:numCaptures*2]
+// ClearMatchData is a no-op kept for compatibility. Match data is no longer
+// stored on the Regexp; each search uses its own buffers.
+func (re *Regexp) ClearMatchData() {
 }
 
-func (re *Regexp) ClearMatchData() {
-	matchData := re.matchData
-	matchData.count = 0
-}
-
-func (re *Regexp) find(b []byte, n int, offset int) (match []int) {
-	if n == 0 {
-		b = []byte{0}
-	}
-	ptr := unsafe.Pointer(&b[0])
-	matchData := re.matchData
-	capturesPtr := unsafe.Pointer(&(matchData.indexes[matchData.count][0]))
-	numCaptures := int32(0)
-	numCapturesPtr := unsafe.Pointer(&numCaptures)
-	pos := int(C.SearchOnigRegex((ptr), C.int(n), C.int(offset), C.int(ONIG_OPTION_DEFAULT), re.regex, re.region, re.errorInfo, (*C.char)(nil), (*C.int)(capturesPtr), (*C.int)(numCapturesPtr)))
-	if pos >= 0 {
-		if numCaptures <= 0 {
-			panic("cannot have 0 captures when processing a match")
-		}
-		match2 := matchData.indexes[matchData.count][:numCaptures*2]
-		match = make([]int, len(match2))
-		for i := range match2 {
-			match[i] = int(match2[i])
-		}
-		numCapturesInPattern := int32(C.onig_number_of_captures(re.regex)) + 1
-		if numCapturesInPattern != numCaptures {
-			log.Fatalf("expected %d captures but got %d\n", numCapturesInPattern, numCaptures)
+func (re *Regexp) find(b []byte, n int, offset int, options SearchOptions) []int {
+	return re.findText(b, n, offset, options, re.goText(b, n, offset, options))
+}
+
+// findText is find for text of which goText has already been checked.
+func (re *Regexp) findText(b []byte, n int, offset int, options SearchOptions, goText bool) (match []int) {
+	options.check()
+	regex := re.acquire()
+	defer re.release()
+	if re.onGo(goText, offset) {
+		match = re.goFind(b, n, offset)
+	} else {
+		match = re.nativeFind(regex, b, n, offset, options)
+	}
+	if match != nil && re.longest != nil {
+		//the leftmost-longest match starts where the leftmost-first one does
+		if longest := re.longest.longestAt(b, n, offset, match[0], options); longest != nil {
+			match = longest
 		}
 	}
 	return
@@ -199,39 +273,64 @@ func getCapture(b []byte, beg int, end int) []byte {
 	return b[beg:end]
 }
 
-func (re *Regexp) match(b []byte, n int, offset int) bool {
-	re.ClearMatchData()
-	if n == 0 {
-		b = []byte{0}
+func (re *Regexp) match(b []byte, n int, offset int, options SearchOptions) bool {
+	options.check()
+	regex := re.acquire()
+	defer re.release()
+	if re.onGo(re.goText(b, n, offset, options), offset) {
+		return re.goRegexp.Match(b[offset:n])
 	}
-	ptr := unsafe.Pointer(&b[0])
-	pos := int(C.SearchOnigRegex((ptr), C.int(n), C.int(offset), C.int(ONIG_OPTION_DEFAULT), re.regex, re.region, re.errorInfo, (*C.char)(nil), (*C.int)(nil), (*C.int)(nil)))
-	return pos >= 0
+	return re.nativeMatch(regex, b, n, offset, options)
 }
 
-func (re *Regexp) findAll(b []byte, n int) (matches [][]int) {
-	re.ClearMatchData()
-
+// findAll returns at most limit successive matches in b[:n], all of them when
+// limit is negative, and all of b when n is.
+func (re *Regexp) findAll(b []byte, n int, limit int, options SearchOptions) (matches [][]int) {
+	//hold one reference across the whole scan so a concurrent Free cannot stop it halfway
+	re.acquire()
+	defer re.release()
 	if n < 0 {
 		n = len(b)
 	}
-	matchData := re.matchData
-	offset := 0
-	for offset <= n {
-		if matchData.count >= len(matchData.indexes) {
-			length := len(matchData.indexes[0])
-			matchData.indexes = append(matchData.indexes, make([]int32, length))
+	if limit == 0 {
+		return [][]int{}
+	}
+	//every search starts on a character boundary, so the text only needs checking once
+	goText := re.goText(b, n, 0, options)
+	find := func(offset int) []int {
+		return re.findText(b, n, offset, options, goText)
+	}
+	if re.longest == nil && re.onGo(goText, 0) {
+		//Go's FindAll differs from the loop below only in dropping empty matches right after a match
+		if !re.goMatchesEmpty || re.goEmptyMatches {
+			matches = re.goRegexp.FindAllSubmatchIndex(b[:n], limit)
+			if matches == nil {
+				matches = [][]int{}
+			}
+			return
+		}
+		if !re.goLeftContext {
+			find = func(offset int) []int {
+				return re.goFind(b, n, offset)
+			}
 		}
-		if match := re.find(b, n, offset); len(match) > 0 {
-			matchData.count += 1
+	}
+	matches = make([][]int, 0, numMatchStartSize)
+	offset := 0
+	prevEnd := -1
+	for offset <= n && (limit < 0 || len(matches) < limit) {
+		if match := find(offset); len(match) > 0 {
+			if !re.goEmptyMatches || match[0] != match[1] || match[0] != prevEnd {
+				matches = append(matches, match)
+			}
+			prevEnd = match[1]
 			//move offset to the ending index of the current match and prepare to find the next non-overlapping match
 			offset = match[1]
 			//if match[0] == match[1], it means the current match does not advance the search. we need to exit the loop to avoid getting stuck here.
 			if match[0] == match[1] {
 				if offset < n && offset >= 0 {
-					//there are more bytes, so move offset by a word
-					_, width := utf8.DecodeRune(b[offset:])
-					offset += width
+					//there are more bytes, so move offset by a character
+					offset += re.charLength(b, n, offset)
 				} else {
 					//search is over, exit loop
 					break
@@ -241,58 +340,92 @@ func (re *Regexp) findAll(b []byte, n int) (matches [][]int) {
 			break
 		}
 	}
-	matches2 := matchData.indexes[:matchData.count]
-	matches = make([][]int, len(matches2))
-	for i, v := range matches2 {
-		matches[i] = make([]int, len(v))
-		for j, v2 := range v {
-			matches[i][j] = int(v2)
-		}
-	}
 	return
 }
 
+// FindIndex returns the start and end of the leftmost match in b, or nil if
+// there is none.
 func (re *Regexp) FindIndex(b []byte) []int {
-	re.ClearMatchData()
-	match := re.find(b, len(b), 0)
+	return re.FindIndexWithOptions(b, ONIG_OPTION_DEFAULT)
+}
+
+// FindIndexWithOptions is FindIndex with the given search options.
+func (re *Regexp) FindIndexWithOptions(b []byte, options SearchOptions) []int {
+	match := re.find(b, len(b), 0, options)
 	if len(match) == 0 {
 		return nil
 	}
 	return match[:2]
 }
 
+// Find returns the text of the leftmost match in b, or nil if there is none.
 func (re *Regexp) Find(b []byte) []byte {
-	loc := re.FindIndex(b)
+	return re.FindWithOptions(b, ONIG_OPTION_DEFAULT)
+}
+
+// FindWithOptions is Find with the given search options.
+func (re *Regexp) FindWithOptions(b []byte, options SearchOptions) []byte {
+	loc := re.FindIndexWithOptions(b, options)
 	if loc == nil {
 		return nil
 	}
 	return getCapture(b, loc[0], loc[1])
 }
 
+// FindString returns the text of the leftmost match in s, or "" if there is
+// none.
 func (re *Regexp) FindString(s string) string {
+	return re.FindStringWithOptions(s, ONIG_OPTION_DEFAULT)
+}
+
+// FindStringWithOptions is FindString with the given search options.
+func (re *Regexp) FindStringWithOptions(s string, options SearchOptions) string {
 	b := []byte(s)
-	mb := re.Find(b)
+	mb := re.FindWithOptions(b, options)
 	if mb == nil {
 		return ""
 	}
 	return string(mb)
 }
 
+// FindStringIndex is FindIndex for a string.
 func (re *Regexp) FindStringIndex(s string) []int {
+	return re.FindStringIndexWithOptions(s, ONIG_OPTION_DEFAULT)
+}
+
+// FindStringIndexWithOptions is FindStringIndex with the given search options.
+func (re *Regexp) FindStringIndexWithOptions(s string, options SearchOptions) []int {
 	b := []byte(s)
-	return re.FindIndex(b)
+	return re.FindIndexWithOptions(b, options)
 }
 
+// FindAllIndex returns the start and end of successive non-overlapping matches
+// in b: at most n of them, or all when n is negative.
 func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
-	matches := re.findAll(b, n)
+	return re.FindAllIndexWithOptions(b, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllIndexWithOptions is FindAllIndex with the given search options.
+func (re *Regexp) FindAllIndexWithOptions(b []byte, n int, options SearchOptions) [][]int {
+	matches := re.findAll(b, -1, n, options)
 	if len(matches) == 0 {
 		return nil
 	}
+	for i, match := range matches {
+		matches[i] = match[:2]
+	}
 	return matches
 }
 
+// FindAll returns the text of successive non-overlapping matches in b, at most
+// n of them, or all when n is negative.
 func (re *Regexp) FindAll(b []byte, n int) [][]byte {
-	matches := re.FindAllIndex(b, n)
+	return re.FindAllWithOptions(b, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllWithOptions is FindAll with the given search options.
+func (re *Regexp) FindAllWithOptions(b []byte, n int, options SearchOptions) [][]byte {
+	matches := re.FindAllIndexWithOptions(b, n, options)
 	if matches == nil {
 		return nil
 	}
@@ -303,9 +436,15 @@ func (re *Regexp) FindAll(b []byte, n int) [][]byte {
 	return matchBytes
 }
 
+// FindAllString is FindAll for a string.
 func (re *Regexp) FindAllString(s string, n int) []string {
+	return re.FindAllStringWithOptions(s, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllStringWithOptions is FindAllString with the given search options.
+func (re *Regexp) FindAllStringWithOptions(s string, n int, options SearchOptions) []string {
 	b := []byte(s)
-	matches := re.FindAllIndex(b, n)
+	matches := re.FindAllIndexWithOptions(b, n, options)
 	if matches == nil {
 		return nil
 	}
@@ -322,27 +461,49 @@ func (re *Regexp) FindAllString(s string, n int) []string {
 
 }
 
+// FindAllStringIndex is FindAllIndex for a string.
 func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	return re.FindAllStringIndexWithOptions(s, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllStringIndexWithOptions is FindAllStringIndex with the given search
+// options.
+func (re *Regexp) FindAllStringIndexWithOptions(s string, n int, options SearchOptions) [][]int {
 	b := []byte(s)
-	return re.FindAllIndex(b, n)
+	return re.FindAllIndexWithOptions(b, n, options)
 }
 
-func (re *Regexp) findSubmatchIndex(b []byte) (match []int) {
-	re.ClearMatchData()
-	match = re.find(b, len(b), 0)
+func (re *Regexp) findSubmatchIndex(b []byte, options SearchOptions) (match []int) {
+	match = re.find(b, len(b), 0, options)
 	return
 }
 
+// FindSubmatchIndex returns the start and end of the leftmost match in b and of
+// each of its groups, -1 for groups that did not take part, or nil if there is
+// no match.
 func (re *Regexp) FindSubmatchIndex(b []byte) []int {
-	match := re.findSubmatchIndex(b)
+	return re.FindSubmatchIndexWithOptions(b, ONIG_OPTION_DEFAULT)
+}
+
+// FindSubmatchIndexWithOptions is FindSubmatchIndex with the given search
+// options.
+func (re *Regexp) FindSubmatchIndexWithOptions(b []byte, options SearchOptions) []int {
+	match := re.findSubmatchIndex(b, options)
 	if len(match) == 0 {
 		return nil
 	}
 	return match
 }
 
+// FindSubmatch returns the text of the leftmost match in b and of each of its
+// groups, or nil if there is no match.
 func (re *Regexp) FindSubmatch(b []byte) [][]byte {
-	match := re.findSubmatchIndex(b)
+	return re.FindSubmatchWithOptions(b, ONIG_OPTION_DEFAULT)
+}
+
+// FindSubmatchWithOptions is FindSubmatch with the given search options.
+func (re *Regexp) FindSubmatchWithOptions(b []byte, options SearchOptions) [][]byte {
+	match := re.findSubmatchIndex(b, options)
 	if match == nil {
 		return nil
 	}
@@ -357,9 +518,16 @@ func (re *Regexp) FindSubmatch(b []byte) [][]byte {
 	return results
 }
 
+// FindStringSubmatch is FindSubmatch for a string.
 func (re *Regexp) FindStringSubmatch(s string) []string {
+	return re.FindStringSubmatchWithOptions(s, ONIG_OPTION_DEFAULT)
+}
+
+// FindStringSubmatchWithOptions is FindStringSubmatch with the given search
+// options.
+func (re *Regexp) FindStringSubmatchWithOptions(s string, options SearchOptions) []string {
 	b := []byte(s)
-	match := re.findSubmatchIndex(b)
+	match := re.findSubmatchIndex(b, options)
 	if match == nil {
 		return nil
 	}
@@ -380,21 +548,41 @@ func (re *Regexp) FindStringSubmatch(s string) []string {
 	return results
 }
 
+// FindStringSubmatchIndex is FindSubmatchIndex for a string.
 func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	return re.FindStringSubmatchIndexWithOptions(s, ONIG_OPTION_DEFAULT)
+}
+
+// FindStringSubmatchIndexWithOptions is FindStringSubmatchIndex with the given
+// search options.
+func (re *Regexp) FindStringSubmatchIndexWithOptions(s string, options SearchOptions) []int {
 	b := []byte(s)
-	return re.FindSubmatchIndex(b)
+	return re.FindSubmatchIndexWithOptions(b, options)
 }
 
+// FindAllSubmatchIndex is the FindAll version of FindSubmatchIndex.
 func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
-	matches := re.findAll(b, n)
+	return re.FindAllSubmatchIndexWithOptions(b, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllSubmatchIndexWithOptions is FindAllSubmatchIndex with the given search
+// options.
+func (re *Regexp) FindAllSubmatchIndexWithOptions(b []byte, n int, options SearchOptions) [][]int {
+	matches := re.findAll(b, -1, n, options)
 	if len(matches) == 0 {
 		return nil
 	}
 	return matches
 }
 
+// FindAllSubmatch is the FindAll version of FindSubmatch.
 func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
-	matches := re.findAll(b, n)
+	return re.FindAllSubmatchWithOptions(b, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllSubmatchWithOptions is FindAllSubmatch with the given search options.
+func (re *Regexp) FindAllSubmatchWithOptions(b []byte, n int, options SearchOptions) [][][]byte {
+	matches := re.findAll(b, -1, n, options)
 	if len(matches) == 0 {
 		return nil
 	}
@@ -411,9 +599,16 @@ func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
 	return allCapturedBytes
 }
 
+// FindAllStringSubmatch is FindAllSubmatch for a string.
 func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	return re.FindAllStringSubmatchWithOptions(s, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllStringSubmatchWithOptions is FindAllStringSubmatch with the given
+// search options.
+func (re *Regexp) FindAllStringSubmatchWithOptions(s string, n int, options SearchOptions) [][]string {
 	b := []byte(s)
-	matches := re.findAll(b, n)
+	matches := re.findAll(b, -1, n, options)
 	if len(matches) == 0 {
 		return nil
 	}
@@ -434,22 +629,41 @@ func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
 	return allCapturedStrings
 }
 
+// FindAllStringSubmatchIndex is FindAllSubmatchIndex for a string.
 func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	return re.FindAllStringSubmatchIndexWithOptions(s, n, ONIG_OPTION_DEFAULT)
+}
+
+// FindAllStringSubmatchIndexWithOptions is FindAllStringSubmatchIndex with the
+// given search options.
+func (re *Regexp) FindAllStringSubmatchIndexWithOptions(s string, n int, options SearchOptions) [][]int {
 	b := []byte(s)
-	return re.FindAllSubmatchIndex(b, n)
+	return re.FindAllSubmatchIndexWithOptions(b, n, options)
 }
 
+// Match reports whether b contains a match.
 func (re *Regexp) Match(b []byte) bool {
-	return re.match(b, len(b), 0)
+	return re.MatchWithOptions(b, ONIG_OPTION_DEFAULT)
+}
+
+// MatchWithOptions is Match with the given search options.
+func (re *Regexp) MatchWithOptions(b []byte, options SearchOptions) bool {
+	return re.match(b, len(b), 0, options)
 }
 
+// MatchString reports whether s contains a match.
 func (re *Regexp) MatchString(s string) bool {
+	return re.MatchStringWithOptions(s, ONIG_OPTION_DEFAULT)
+}
+
+// MatchStringWithOptions is MatchString with the given search options.
+func (re *Regexp) MatchStringWithOptions(s string, options SearchOptions) bool {
 	b := []byte(s)
-	return re.Match(b)
+	return re.MatchWithOptions(b, options)
 }
 
 func (re *Regexp) NumSubexp() int {
-	return (int)(C.onig_number_of_captures(re.regex))
+	return re.numCaptures - 1
 }
 
 func (re *Regexp) getNamedCapture(name []byte, capturedBytes [][]byte) []byte {
@@ -469,47 +683,51 @@ func (re *Regexp) getNumberedCapture(num int, capturedBytes [][]byte) []byte {
 	return ([]byte)("")
 }
 
-func fillCapturedValues(repl []byte, _ []byte, capturedBytes map[string][]byte) []byte {
+func (re *Regexp) fillCapturedValues(repl []byte, _ []byte, capturedBytes map[string][]byte) []byte {
+	t := re.templateText(string(repl))
 	replLen := len(repl)
 	newRepl := make([]byte, 0, replLen*3)
 	inEscapeMode := false
 	inGroupNameMode := false
 	groupName := make([]byte, 0, replLen)
-	for index := 0; index < replLen; index += 1 {
-		ch := repl[index]
-		if inGroupNameMode && ch == byte('<') {
-		} else if inGroupNameMode && ch == byte('>') {
+	for index := 0; index < replLen; index += t.size {
+		ch := t.at(index)
+		unit := t.unit(index)
+		if inGroupNameMode && ch == '<' {
+		} else if inGroupNameMode && ch == '>' {
 			inGroupNameMode = false
 			groupNameStr := string(groupName)
 			capBytes := capturedBytes[groupNameStr]
 			newRepl = append(newRepl, capBytes...)
 			groupName = groupName[:0] //reset the name
 		} else if inGroupNameMode {
-			groupName = append(groupName, ch)
-		} else if inEscapeMode && ch <= byte('9') && byte('1') <= ch {
+			groupName = append(groupName, unit...)
+		} else if inEscapeMode && ch <= '9' && '1' <= ch {
 			capNumStr := string(ch)
 			capBytes := capturedBytes[capNumStr]
 			newRepl = append(newRepl, capBytes...)
-		} else if inEscapeMode && ch == byte('k') && (index+1) < replLen && repl[index+1] == byte('<') {
+		} else if inEscapeMode && ch == 'k' && t.at(index+t.size) == '<' {
 			inGroupNameMode = true
 			inEscapeMode = false
-			index += 1 //bypass the next char '<'
+			index += t.size //bypass the next char '<'
+		} else if inEscapeMode && ch == '\\' {
+			newRepl = append(newRepl, unit...)
 		} else if inEscapeMode {
-			newRepl = append(newRepl, '\\')
-			newRepl = append(newRepl, ch)
+			newRepl = t.appendASCII(newRepl, '\\')
+			newRepl = append(newRepl, unit...)
 		} else if ch != '\\' {
-			newRepl = append(newRepl, ch)
+			newRepl = append(newRepl, unit...)
 		}
-		if ch == byte('\\') || inEscapeMode {
+		if ch == '\\' || inEscapeMode {
 			inEscapeMode = !inEscapeMode
 		}
 	}
 	return newRepl
 }
 
-func (re *Regexp) replaceAll(src, repl []byte, replFunc func([]byte, []byte, map[string][]byte) []byte) []byte {
+func (re *Regexp) replaceAll(src, repl []byte, replFunc func([]byte, []byte, map[string][]byte) []byte, options SearchOptions) []byte {
 	srcLen := len(src)
-	matches := re.findAll(src, srcLen)
+	matches := re.findAll(src, srcLen, -1, options)
 	if len(matches) == 0 {
 		return src
 	}
@@ -545,25 +763,81 @@ func (re *Regexp) replaceAll(src, repl []byte, replFunc func([]byte, []byte, map
 	return dest
 }
 
+// ReplaceAll returns a copy of src with every match replaced by repl, in which
+// \1 and \k<name> stand for groups of the match.
 func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
-	return re.replaceAll(src, repl, fillCapturedValues)
+	return re.ReplaceAllWithOptions(src, repl, ONIG_OPTION_DEFAULT)
+}
+
+// ReplaceAllWithOptions is ReplaceAll with the given search options.
+func (re *Regexp) ReplaceAllWithOptions(src, repl []byte, options SearchOptions) []byte {
+	return re.replaceAll(src, repl, re.fillCapturedValues, options)
+}
+
+// ReplaceAllLiteral returns a copy of src, replacing matches of the Regexp with
+// the replacement bytes repl. The replacement is substituted directly, without
+// expanding \1 or \k<name>.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	return re.ReplaceAllLiteralWithOptions(src, repl, ONIG_OPTION_DEFAULT)
 }
 
+// ReplaceAllLiteralWithOptions is ReplaceAllLiteral with the given search
+// options.
+func (re *Regexp) ReplaceAllLiteralWithOptions(src, repl []byte, options SearchOptions) []byte {
+	return re.replaceAll(src, repl, func(repl []byte, _ []byte, _ map[string][]byte) []byte {
+		return repl
+	}, options)
+}
+
+// ReplaceAllFunc returns a copy of src with every match replaced by what repl
+// returns for it.
 func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return re.ReplaceAllFuncWithOptions(src, repl, ONIG_OPTION_DEFAULT)
+}
+
+// ReplaceAllFuncWithOptions is ReplaceAllFunc with the given search options.
+func (re *Regexp) ReplaceAllFuncWithOptions(src []byte, repl func([]byte) []byte, options SearchOptions) []byte {
 	return re.replaceAll(src, []byte(""), func(_ []byte, matchBytes []byte, _ map[string][]byte) []byte {
 		return repl(matchBytes)
-	})
+	}, options)
 }
 
+// ReplaceAllString is ReplaceAll for strings.
 func (re *Regexp) ReplaceAllString(src, repl string) string {
-	return string(re.ReplaceAll([]byte(src), []byte(repl)))
+	return re.ReplaceAllStringWithOptions(src, repl, ONIG_OPTION_DEFAULT)
 }
 
+// ReplaceAllStringWithOptions is ReplaceAllString with the given search
+// options.
+func (re *Regexp) ReplaceAllStringWithOptions(src, repl string, options SearchOptions) string {
+	return string(re.ReplaceAllWithOptions([]byte(src), []byte(repl), options))
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of the
+// Regexp with the replacement string repl. The replacement is substituted
+// directly, without expanding \1 or \k<name>.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return re.ReplaceAllLiteralStringWithOptions(src, repl, ONIG_OPTION_DEFAULT)
+}
+
+// ReplaceAllLiteralStringWithOptions is ReplaceAllLiteralString with the given
+// search options.
+func (re *Regexp) ReplaceAllLiteralStringWithOptions(src, repl string, options SearchOptions) string {
+	return string(re.ReplaceAllLiteralWithOptions([]byte(src), []byte(repl), options))
+}
+
+// ReplaceAllStringFunc is ReplaceAllFunc for strings.
 func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	return re.ReplaceAllStringFuncWithOptions(src, repl, ONIG_OPTION_DEFAULT)
+}
+
+// ReplaceAllStringFuncWithOptions is ReplaceAllStringFunc with the given search
+// options.
+func (re *Regexp) ReplaceAllStringFuncWithOptions(src string, repl func(string) string, options SearchOptions) string {
 	srcB := []byte(src)
 	destB := re.replaceAll(srcB, []byte(""), func(_ []byte, matchBytes []byte, _ map[string][]byte) []byte {
 		return []byte(repl(string(matchBytes)))
-	})
+	}, options)
 	return string(destB)
 }
 
@@ -571,70 +845,47 @@ func (re *Regexp) String() string {
 	return re.pattern
 }
 
-func grow_buffer(b []byte, offset int, n int) []byte {
-	if offset+n > cap(b) {
-		buf := make([]byte, 2*cap(b)+n)
-		copy(buf, b[:offset])
-		return buf
-	}
-	return b
-}
-
-func fromReader(r io.RuneReader) []byte {
-	b := make([]byte, numReadBufferStartSize)
-	offset := 0
-	var err error = nil
-	for err == nil {
-		rune, runeWidth, err := r.ReadRune()
-		if err == nil {
-			b = grow_buffer(b, offset, runeWidth)
-			writeWidth := utf8.EncodeRune(b[offset:], rune)
-			if runeWidth != writeWidth {
-				panic("reading rune width not equal to the written rune width")
-			}
-			offset += writeWidth
-		} else {
-			break
-		}
-	}
-	return b[:offset]
-}
-
+// FindReaderIndex returns the start and end of the leftmost match of the
+// regular expression in the text read from r, or nil. The text is searched as
+// it is read, so matches longer than DefaultMaxMatchLength may be cut short or
+// missed; use a StreamSearcher for other limits and for offsets past the range
+// of an int.
 func (re *Regexp) FindReaderIndex(r io.RuneReader) []int {
-	b := fromReader(r)
-	return re.FindIndex(b)
+	if match := re.findReader(r); match != nil {
+		return match[:2]
+	}
+	return nil
 }
 
+// FindReaderSubmatchIndex is FindReaderIndex returning the offsets of the
+// groups as well, as FindSubmatchIndex does.
 func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
-	b := fromReader(r)
-	return re.FindSubmatchIndex(b)
+	return re.findReader(r)
 }
 
+// MatchReader reports whether the text read from r contains a match, with the
+// same limit on the match length as FindReaderIndex.
 func (re *Regexp) MatchReader(r io.RuneReader) bool {
-	b := fromReader(r)
-	return re.Match(b)
-}
-
-func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
-	//no easy way to implement this
-	return "", false
-}
-
-func MatchString(pattern string, s string) (matched bool, error error) {
-	re, err := Compile(pattern)
-	if err != nil {
-		return false, err
-	}
-	return re.MatchString(s), nil
+	return NewStreamSearcher(re, asReader(r), 0).Next()
 }
 
+// Gsub is ReplaceAllString.
 func (re *Regexp) Gsub(src, repl string) string {
 	srcBytes := ([]byte)(src)
 	replBytes := ([]byte)(repl)
-	replaced := re.replaceAll(srcBytes, replBytes, fillCapturedValues)
+	replaced := re.replaceAll(srcBytes, replBytes, re.fillCapturedValues, ONIG_OPTION_DEFAULT)
 	return string(replaced)
 }
 
+// QuoteReplacement returns a string that escapes every backslash in s, so that
+// Gsub, ReplaceAll and ReplaceAllString insert s literally instead of
+// expanding \1 or \k<name> in it.
+func QuoteReplacement(s string) string {
+	return strings.ReplaceAll(s, `\`, `\\`)
+}
+
+// GsubFunc returns a copy of src with every match replaced by what replFunc
+// returns for the matched text and the text of the named groups.
 func (re *Regexp) GsubFunc(src string, replFunc func(string, map[string]string) string) string {
 	srcBytes := ([]byte)(src)
 	replaced := re.replaceAll(srcBytes, nil, func(_ []byte, matchBytes []byte, capturedBytes map[string][]byte) []byte {
@@ -644,6 +895,6 @@ func (re *Regexp) GsubFunc(src string, replFunc func(string, map[string]string)
 		}
 		matchString := string(matchBytes)
 		return ([]byte)(replFunc(matchString, capturedStrings))
-	})
+	}, ONIG_OPTION_DEFAULT)
 	return string(replaced)
 }
@@ -0,0 +1,148 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+/*
+#cgo !onig_static pkg-config: oniguruma
+#cgo onig_static pkg-config: --static oniguruma
+#include <stdlib.h>
+#include <oniguruma.h>
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"unsafe"
+)
+
+// nativeRegex is the compiled Oniguruma regex.
+type nativeRegex = C.OnigRegex
+
+// Oniguruma is initialized once, before any pattern is compiled, so that
+// NewRegexp needs no lock and independent patterns compile in parallel.
+func init() {
+	if ret := C.InitOnig(); ret != C.ONIG_NORMAL {
+		panic(fmt.Sprintf("rubex: failed to initialize oniguruma (error %d)", int(ret)))
+	}
+}
+
+func libraryVersion() string {
+	return C.GoString(C.onig_version())
+}
+
+func (re *Regexp) compile() error {
+	onigSyntax := re.syntax.onigSyntax()
+	if onigSyntax == nil {
+		return fmt.Errorf("rubex: unknown syntax %d", int(re.syntax))
+	}
+	onigEncoding := re.encoding.onigEncoding()
+	if onigEncoding == nil {
+		return fmt.Errorf("rubex: unknown encoding %d", int(re.encoding))
+	}
+	patternCharPtr := C.CString(re.pattern)
+	defer C.free(unsafe.Pointer(patternCharPtr))
+	errorBuf := make([]byte, C.ONIG_MAX_ERROR_MESSAGE_LEN)
+
+	//Oniguruma's ONIG_OPTION_FIND_LONGEST is not leftmost-longest; see Longest
+	option := (re.option | re.implied) &^ ONIG_OPTION_FIND_LONGEST
+	error_code := C.NewOnigRegex(patternCharPtr, C.int(len(re.pattern)), C.int(option), onigSyntax, onigEncoding, &re.regex, (*C.char)(unsafe.Pointer(&errorBuf[0])))
+	if error_code != C.ONIG_NORMAL {
+		return errors.New(C.GoString((*C.char)(unsafe.Pointer(&errorBuf[0]))))
+	}
+	re.numCaptures = int(C.onig_number_of_captures(re.regex)) + 1
+	re.setGroupNames(re.getGroupNames())
+	return nil
+}
+
+func freeNativeRegex(regex nativeRegex) {
+	C.onig_free(regex)
+}
+
+// getGroupNames maps each group name to the numbers of the groups carrying it,
+// in pattern order.
+func (re *Regexp) getGroupNames() (groupNames map[string][]int) {
+	numNamedGroups := int(C.onig_number_of_names(re.regex))
+	//when any named capture exisits, there is no numbered capture even if there are unnamed captures
+	if numNamedGroups > 0 {
+		groupNames = make(map[string][]int)
+		//try to get the names
+		bufferSize := len(re.pattern) * 2
+		nameBuffer := make([]byte, bufferSize)
+		groupNumbers := make([]int32, numNamedGroups)
+		bufferPtr := unsafe.Pointer(&nameBuffer[0])
+		numbersPtr := unsafe.Pointer(&groupNumbers[0])
+		length := int(C.GetCaptureNames(re.regex, bufferPtr, (C.int)(bufferSize), (*C.int)(numbersPtr)))
+		if length > 0 {
+			namesAsBytes := bytes.Split(nameBuffer[:length], ([]byte)(";"))
+			if len(namesAsBytes) != numNamedGroups {
+				log.Fatalf("the number of named groups (%d) does not match the number names found (%d)\n", numNamedGroups, len(namesAsBytes))
+			}
+			for _, nameAsBytes := range namesAsBytes {
+				//a name can be given to several groups
+				var nums *C.int
+				nameStart := (*C.OnigUChar)(unsafe.Pointer(&nameAsBytes[0]))
+				nameEnd := (*C.OnigUChar)(unsafe.Add(unsafe.Pointer(nameStart), len(nameAsBytes)))
+				count := int(C.onig_name_to_group_numbers(re.regex, nameStart, nameEnd, &nums))
+				groups := make([]int, count)
+				for i, num := range unsafe.Slice(nums, count) {
+					groups[i] = int(num)
+				}
+				groupNames[string(nameAsBytes)] = groups
+			}
+		} else {
+			log.Fatalf("could not get the capture group names from %q", re.String())
+		}
+	}
+	return
+}
+
+func (re *Regexp) nativeFind(regex nativeRegex, b []byte, n int, offset int, options SearchOptions) (match []int) {
+	if n == 0 {
+		b = []byte{0}
+	}
+	ptr := unsafe.Pointer(&b[0])
+	captures := make([]int32, re.numCaptures*2)
+	capturesPtr := unsafe.Pointer(&captures[0])
+	numCaptures := int32(0)
+	numCapturesPtr := unsafe.Pointer(&numCaptures)
+	pos := C.SearchOnigRegex((ptr), C.int(n), C.int(offset), C.int(options), regex, (*C.int)(capturesPtr), (*C.int)(numCapturesPtr))
+	if pos < 0 && pos != C.ONIG_MISMATCH {
+		panic(searchError(pos))
+	}
+	if pos >= 0 {
+		if numCaptures <= 0 {
+			panic("cannot have 0 captures when processing a match")
+		}
+		if int(numCaptures) != re.numCaptures {
+			log.Fatalf("expected %d captures but got %d\n", re.numCaptures, numCaptures)
+		}
+		match = make([]int, len(captures))
+		for i := range captures {
+			match[i] = int(captures[i])
+		}
+	}
+	return
+}
+
+func (re *Regexp) nativeMatch(regex nativeRegex, b []byte, n int, offset int, options SearchOptions) bool {
+	if n == 0 {
+		b = []byte{0}
+	}
+	ptr := unsafe.Pointer(&b[0])
+	pos := C.SearchOnigRegex((ptr), C.int(n), C.int(offset), C.int(options), regex, (*C.int)(nil), (*C.int)(nil))
+	if pos < 0 && pos != C.ONIG_MISMATCH {
+		panic(searchError(pos))
+	}
+	return pos >= 0
+}
+
+// searchError describes an error code onig_search returned.
+func searchError(code C.int) *SearchError {
+	errorBuf := make([]byte, C.ONIG_MAX_ERROR_MESSAGE_LEN)
+	C.OnigErrorMessage(code, (*C.char)(unsafe.Pointer(&errorBuf[0])))
+	return &SearchError{Code: int(code), Message: C.GoString((*C.char)(unsafe.Pointer(&errorBuf[0])))}
+}
@@ -0,0 +1,61 @@
+//go:build nocgo || !cgo
+
+package rubex
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Without cgo, patterns run on the backtracker, which handles the Ruby
+// syntax on UTF-8 text.
+type nativeRegex = *backtracker
+
+// there is no Oniguruma library to report
+func libraryVersion() string {
+	return ""
+}
+
+func (re *Regexp) compile() error {
+	if re.syntax != ONIG_SYNTAX_RUBY {
+		if _, name := lookupCustomSyntax(re.syntax); name == "" && (re.syntax < 0 || int(re.syntax) >= len(syntaxNames)) {
+			return fmt.Errorf("rubex: unknown syntax %d", int(re.syntax))
+		}
+		return fmt.Errorf("rubex: %v is not supported without cgo", re.syntax)
+	}
+	if re.encoding != ONIG_ENCODING_UTF8 {
+		if re.encoding < 0 || int(re.encoding) >= len(encodingNames) {
+			return fmt.Errorf("rubex: unknown encoding %d", int(re.encoding))
+		}
+		return fmt.Errorf("rubex: encoding %v is not supported without cgo", re.encoding)
+	}
+	// leftmost-longest matches are found by re.longest; see Longest
+	bt, err := newBacktracker(re.pattern, re.option&^ONIG_OPTION_FIND_LONGEST)
+	if err != nil {
+		// report syntax errors the way Oniguruma words them
+		if perr, ok := err.(*parseError); ok {
+			return errors.New(perr.msg)
+		}
+		return err
+	}
+	re.regex = bt
+	re.numCaptures = bt.numCaptures
+	re.setGroupNames(bt.names)
+	return nil
+}
+
+func freeNativeRegex(regex nativeRegex) {
+}
+
+func (re *Regexp) nativeFind(regex nativeRegex, b []byte, n int, offset int, options SearchOptions) []int {
+	return regex.search(b, n, offset, options)
+}
+
+func (re *Regexp) nativeMatch(regex nativeRegex, b []byte, n int, offset int, options SearchOptions) bool {
+	return regex.search(b, n, offset, options) != nil
+}
+
+// only UTF-8 text can be searched
+func (re *Regexp) nativeCharLength(b []byte, n int, offset int) int {
+	return 1
+}
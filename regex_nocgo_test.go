@@ -0,0 +1,19 @@
+//go:build nocgo || !cgo
+
+package rubex
+
+import (
+	"testing"
+)
+
+func TestNoCgoUnsupported(t *testing.T) {
+	if _, err := CompileWithSyntax(`a`, ONIG_OPTION_DEFAULT, ONIG_SYNTAX_PERL); err == nil {
+		t.Errorf("expected an error for Perl syntax")
+	}
+	if _, err := CompileWithEncoding(`a`, ONIG_OPTION_DEFAULT, ONIG_ENCODING_SJIS); err == nil {
+		t.Errorf("expected an error for Shift_JIS")
+	}
+	if _, err := Compile(`a\Kb`); err == nil || err.Error() != `\K is not supported without cgo` {
+		t.Errorf(`Compile("a\\Kb") = %v; want an error for \K`, err)
+	}
+}
@@ -0,0 +1,60 @@
+package rubex
+
+import (
+	"testing"
+)
+
+var literalReplaceTests = []struct {
+	pattern, input, replacement, output string
+}{
+	{`b+`, "abbc", `\1`, `a\1c`},
+	{`(b)`, "abc", `\\`, `a\\c`},
+	{`(?<x>b)`, "abc", `\k<x>`, `a\k<x>c`},
+	{`b`, "abc", `0\`, `a0\c`},
+	{`x*`, "ab", `$1`, `$1a$1b$1`},
+	{`b`, "abc", "", "ac"},
+}
+
+func TestReplaceAllLiteral(t *testing.T) {
+	for _, test := range literalReplaceTests {
+		re := MustCompile(test.pattern)
+		if actual := re.ReplaceAllLiteralString(test.input, test.replacement); actual != test.output {
+			t.Errorf("%q.ReplaceAllLiteralString(%q, %q) = %q; want %q", test.pattern, test.input, test.replacement, actual, test.output)
+		}
+		if actual := string(re.ReplaceAllLiteral([]byte(test.input), []byte(test.replacement))); actual != test.output {
+			t.Errorf("%q.ReplaceAllLiteral(%q, %q) = %q; want %q", test.pattern, test.input, test.replacement, actual, test.output)
+		}
+		if actual := re.Gsub(test.input, QuoteReplacement(test.replacement)); actual != test.output {
+			t.Errorf("%q.Gsub(%q, QuoteReplacement(%q)) = %q; want %q", test.pattern, test.input, test.replacement, actual, test.output)
+		}
+		if actual := re.ReplaceAllString(test.input, QuoteReplacement(test.replacement)); actual != test.output {
+			t.Errorf("%q.ReplaceAllString(%q, QuoteReplacement(%q)) = %q; want %q", test.pattern, test.input, test.replacement, actual, test.output)
+		}
+	}
+	re := MustCompile(`\s`)
+	if actual := re.ReplaceAllLiteralStringWithOptions("a b\nc", "_", ONIG_OPTION_NOTBOL); actual != "a_b_c" {
+		t.Errorf("ReplaceAllLiteralStringWithOptions = %q", actual)
+	}
+}
+
+func TestQuoteReplacement(t *testing.T) {
+	tests := []struct {
+		input, output string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{`\`, `\\`},
+		{`\1\k<name>`, `\\1\\k<name>`},
+		{`a\\b`, `a\\\\b`},
+	}
+	for _, test := range tests {
+		if actual := QuoteReplacement(test.input); actual != test.output {
+			t.Errorf("QuoteReplacement(%q) = %q; want %q", test.input, actual, test.output)
+		}
+	}
+	// a backslash pair in a template is one backslash
+	re := MustCompile(`(b)`)
+	if actual := re.Gsub("abc", `\\\1`); actual != `a\bc` {
+		t.Errorf(`Gsub("abc", "\\\\\\1") = %q; want %q`, actual, `a\bc`)
+	}
+}
@@ -0,0 +1,30 @@
+package rubex
+
+import (
+	"strconv"
+)
+
+// SearchOptions change how a single search runs, independent of the options
+// the pattern was compiled with. Combine the search-time option constants:
+//
+//	ONIG_OPTION_NOTBOL          the start of the text is not the beginning of a line
+//	ONIG_OPTION_NOTEOL          the end of the text is not the end of a line
+//	ONIG_OPTION_FIND_NOT_EMPTY  ignore empty matches
+//	ONIG_OPTION_FIND_LONGEST    report the longest match
+//
+// Oniguruma's longest match is the longest of the first matches found at each
+// position of the text, which need not start leftmost; Longest gives
+// leftmost-longest matches.
+//
+// Searching a chunk cut out of a larger text with ONIG_OPTION_NOTBOL and
+// ONIG_OPTION_NOTEOL keeps ^ and $ from matching at the cut.
+type SearchOptions int
+
+const searchOptionsMask = ONIG_OPTION_NOTBOL | ONIG_OPTION_NOTEOL | ONIG_OPTION_FIND_NOT_EMPTY | ONIG_OPTION_FIND_LONGEST
+
+// check panics if the options contain anything but search-time options.
+func (options SearchOptions) check() {
+	if options&^searchOptionsMask != 0 {
+		panic("rubex: invalid search options " + strconv.Itoa(int(options)))
+	}
+}
@@ -0,0 +1,87 @@
+package rubex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type searchOptionsTest struct {
+	pattern string
+	text    string
+	options SearchOptions
+	matches [][]int
+}
+
+var searchOptionsTests = []searchOptionsTest{
+	{`^\w+`, "ab\ncd", ONIG_OPTION_DEFAULT, build(2, 0, 2, 3, 5)},
+	// ^ no longer matches at the start of the text, but still after a newline
+	{`^\w+`, "ab\ncd", ONIG_OPTION_NOTBOL, build(1, 3, 5)},
+	{`\w+$`, "ab\ncd", ONIG_OPTION_NOTEOL, build(1, 0, 2)},
+	{`^\w+$`, "ab", ONIG_OPTION_NOTBOL | ONIG_OPTION_NOTEOL, nil},
+	{`a*`, "baac", ONIG_OPTION_DEFAULT, build(4, 0, 0, 1, 3, 3, 3, 4, 4)},
+	{`a*`, "baac", ONIG_OPTION_FIND_NOT_EMPTY, build(1, 1, 3)},
+}
+
+func TestFindAllIndexWithOptions(t *testing.T) {
+	for _, tc := range searchOptionsTests {
+		re := MustCompile(tc.pattern)
+		actual := re.FindAllIndexWithOptions([]byte(tc.text), -1, tc.options)
+		if !reflect.DeepEqual(actual, tc.matches) {
+			t.Errorf("%q.FindAllIndexWithOptions(%q, %d) = %v; want %v", tc.pattern, tc.text, tc.options, actual, tc.matches)
+		}
+		if matched := re.MatchStringWithOptions(tc.text, tc.options); matched != (tc.matches != nil) {
+			t.Errorf("%q.MatchStringWithOptions(%q, %d) = %v", tc.pattern, tc.text, tc.options, matched)
+		}
+		var first []int
+		if tc.matches != nil {
+			first = tc.matches[0]
+		}
+		if actual := re.FindStringIndexWithOptions(tc.text, tc.options); !reflect.DeepEqual(actual, first) {
+			t.Errorf("%q.FindStringIndexWithOptions(%q, %d) = %v; want %v", tc.pattern, tc.text, tc.options, actual, first)
+		}
+	}
+}
+
+func TestReplaceAllWithOptions(t *testing.T) {
+	re := MustCompile(`^(\w)`)
+	if actual := re.ReplaceAllStringWithOptions("ab\ncd", "<\\1>", ONIG_OPTION_NOTBOL); actual != "ab\n<c>d" {
+		t.Errorf("ReplaceAllStringWithOptions = %q", actual)
+	}
+	re = MustCompile(`x*`)
+	if actual := re.ReplaceAllStringFuncWithOptions("axxb", func(s string) string { return "-" }, ONIG_OPTION_FIND_NOT_EMPTY); actual != "a-b" {
+		t.Errorf("ReplaceAllStringFuncWithOptions = %q", actual)
+	}
+}
+
+func TestInvalidSearchOptions(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a compile-time option")
+		}
+	}()
+	MustCompile("a").MatchStringWithOptions("a", ONIG_OPTION_IGNORECASE)
+}
+
+func TestSearchError(t *testing.T) {
+	if !Features().AtLeast(6, 9, 0) {
+		t.Skip("needs an Oniguruma with a retry limit")
+	}
+	// backtracks far past Oniguruma's default retry limit before failing
+	re := MustCompile(`(?:(\w+)\s?)*$`)
+	text := strings.Repeat("ab ", 100) + "!"
+	searches := map[string]func(){
+		"FindStringIndex": func() { re.FindStringIndex(text) },
+		"MatchString":     func() { re.MatchString(text) },
+	}
+	for name, search := range searches {
+		func() {
+			defer func() {
+				if err, ok := recover().(*SearchError); !ok || err.Code >= -1 || err.Message == "" {
+					t.Errorf("%s panicked with %v; want a SearchError", name, err)
+				}
+			}()
+			search()
+		}()
+	}
+}
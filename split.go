@@ -0,0 +1,137 @@
+package rubex
+
+// Split slices s into substrings separated by the expression and returns a
+// slice of the substrings between those expression matches, as
+// regexp.Regexp.Split does. An empty match right after the previous match
+// does not split.
+//
+// The count determines the number of substrings to return:
+//
+//	n > 0: at most n substrings; the last substring will be the unsplit remainder.
+//	n == 0: the result is nil (zero substrings)
+//	n < 0: all substrings
+func (re *Regexp) Split(s string, n int) []string {
+	fields := re.splitGo([]byte(s), n)
+	if fields == nil {
+		return nil
+	}
+	strs := make([]string, len(fields))
+	for i, field := range fields {
+		strs[i] = s[field[0]:field[1]]
+	}
+	return strs
+}
+
+// SplitBytes is Split for a byte slice. The returned slices share b's memory.
+func (re *Regexp) SplitBytes(b []byte, n int) [][]byte {
+	fields := re.splitGo(b, n)
+	if fields == nil {
+		return nil
+	}
+	slices := make([][]byte, len(fields))
+	for i, field := range fields {
+		slices[i] = b[field[0]:field[1]:field[1]]
+	}
+	return slices
+}
+
+// RubySplit divides s around the matches of the expression, as Ruby's
+// String#split does:
+//
+//	limit > 0: at most limit fields; the last field is the unsplit remainder
+//	limit == 0: all fields, with trailing empty strings removed
+//	limit < 0: all fields, keeping trailing empty strings
+//
+// The text of each participating capture group is added after the field that
+// precedes its match, without counting against the limit. An empty match only
+// splits when it does not touch the end of the previous split, so an empty
+// pattern splits s into characters. An empty s gives no fields.
+func (re *Regexp) RubySplit(s string, limit int) []string {
+	fields := re.splitRuby([]byte(s), limit)
+	strs := make([]string, len(fields))
+	for i, field := range fields {
+		strs[i] = s[field[0]:field[1]]
+	}
+	return strs
+}
+
+// RubySplitBytes is RubySplit for a byte slice. The returned slices share b's
+// memory.
+func (re *Regexp) RubySplitBytes(b []byte, limit int) [][]byte {
+	fields := re.splitRuby(b, limit)
+	slices := make([][]byte, len(fields))
+	for i, field := range fields {
+		slices[i] = b[field[0]:field[1]:field[1]]
+	}
+	return slices
+}
+
+// splitGo returns the start and end of each field Split returns.
+func (re *Regexp) splitGo(b []byte, n int) (fields [][]int) {
+	if n == 0 {
+		return nil
+	}
+	if len(re.pattern) > 0 && len(b) == 0 {
+		return [][]int{{0, 0}}
+	}
+	fields = make([][]int, 0, numMatchStartSize)
+	beg, end, prevEnd := 0, 0, -1
+	for _, match := range re.findAll(b, -1, -1, ONIG_OPTION_DEFAULT) {
+		//Go's regexp does not report an empty match right after a match
+		if match[0] == match[1] && match[0] == prevEnd {
+			continue
+		}
+		prevEnd = match[1]
+		if n > 0 && len(fields) == n-1 {
+			break
+		}
+		end = match[0]
+		if match[1] != 0 {
+			fields = append(fields, []int{beg, end})
+		}
+		beg = match[1]
+	}
+	if end != len(b) {
+		fields = append(fields, []int{beg, len(b)})
+	}
+	return
+}
+
+// splitRuby returns the start and end of each field, and of each capture
+// added between fields, RubySplit returns.
+func (re *Regexp) splitRuby(b []byte, limit int) (fields [][]int) {
+	if limit == 1 {
+		if len(b) == 0 {
+			return nil
+		}
+		return [][]int{{0, len(b)}}
+	}
+	fields = make([][]int, 0, numMatchStartSize)
+	beg, splits := 0, 0
+	for _, match := range re.findAll(b, -1, -1, ONIG_OPTION_DEFAULT) {
+		if limit > 0 && splits == limit-1 {
+			break
+		}
+		//an empty match where the previous field ended does not split
+		if match[0] == match[1] && match[0] == beg {
+			continue
+		}
+		fields = append(fields, []int{beg, match[0]})
+		for i := 2; i < len(match); i += 2 {
+			if match[i] >= 0 {
+				fields = append(fields, match[i:i+2])
+			}
+		}
+		beg = match[1]
+		splits++
+	}
+	if len(b) > 0 && (limit != 0 || beg < len(b)) {
+		fields = append(fields, []int{beg, len(b)})
+	}
+	if limit == 0 {
+		for len(fields) > 0 && fields[len(fields)-1][0] == fields[len(fields)-1][1] {
+			fields = fields[:len(fields)-1]
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package rubex
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var splitTests = []struct {
+	s       string
+	pattern string
+	n       int
+}{
+	{"foo:and:bar", ":", -1},
+	{"foo:and:bar", ":", 1},
+	{"foo:and:bar", ":", 2},
+	{"foo:and:bar", "foo", -1},
+	{"foo:and:bar", "bar", -1},
+	{"foo:and:bar", "baz", -1},
+	{"baabaab", "a", -1},
+	{"baabaab", "a*", -1},
+	{"baabaab", "ba*", -1},
+	{"foobar", "f*b*", -1},
+	{"foobar", "f+.*b+", -1},
+	{"foobooboar", "o{2}", -1},
+	{"a,b,c,d,e,f", ",", 3},
+	{"a,b,c,d,e,f", ",", 0},
+	{",", ",", -1},
+	{",,,", ",", -1},
+	{"", ",", -1},
+	{"", ".*", -1},
+	{"", ".+", -1},
+	{"", "", -1},
+	{"foobar", "", -1},
+	{"abaabaccadaaae", "a*", 5},
+	{":x:y:z:", ":", -1},
+	{"héllo wörld", "", -1},
+	{"héllo wörld", `\s*`, -1},
+	{"héllo wörld", "ö", -1},
+	{"αβγ", "β*", 2},
+	{"a\xffb", "", -1},
+	{"a1b22c", `(\d)`, -1},
+}
+
+func TestSplit(t *testing.T) {
+	for _, test := range splitTests {
+		expected := regexp.MustCompile(test.pattern).Split(test.s, test.n)
+		re := MustCompile(test.pattern)
+		if actual := re.Split(test.s, test.n); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q.Split(%q, %d) = %q; want %q", test.pattern, test.s, test.n, actual, expected)
+		}
+		actual := re.SplitBytes([]byte(test.s), test.n)
+		if (actual == nil) != (expected == nil) || len(actual) != len(expected) {
+			t.Errorf("%q.SplitBytes(%q, %d) = %q; want %q", test.pattern, test.s, test.n, actual, expected)
+			continue
+		}
+		for i := range actual {
+			if string(actual[i]) != expected[i] {
+				t.Errorf("%q.SplitBytes(%q, %d) = %q; want %q", test.pattern, test.s, test.n, actual, expected)
+				break
+			}
+		}
+	}
+}
+
+// Results of String#split in Ruby.
+var rubySplitTests = []struct {
+	s        string
+	pattern  string
+	limit    int
+	expected []string
+}{
+	{" now's  the time", ` `, 0, []string{"", "now's", "", "the", "time"}},
+	{"1, 2.34,56, 7", `,\s*`, 0, []string{"1", "2.34", "56", "7"}},
+	{"hi mom", `\s*`, 0, []string{"h", "i", "m", "o", "m"}},
+	{"mellow yellow", `ello`, 0, []string{"m", "w y", "w"}},
+	{"1,2,,3,4,,", `,`, 0, []string{"1", "2", "", "3", "4"}},
+	{"1,2,,3,4,,", `,`, 4, []string{"1", "2", "", "3,4,,"}},
+	{"1,2,,3,4,,", `,`, -4, []string{"1", "2", "", "3", "4", "", ""}},
+	{"1:2:3", `(:)()()`, 2, []string{"1", ":", "", "", "2:3"}},
+	{"aXbXc", `(X)`, 0, []string{"a", "X", "b", "X", "c"}},
+	{"a b", `(x)?\s`, 0, []string{"a", "b"}},
+	{"a1b2c3", `\d`, 0, []string{"a", "b", "c"}},
+	{"a1b2c3", `(\d)`, 0, []string{"a", "1", "b", "2", "c", "3"}},
+	{"a,b,", `(,)|(x)`, -1, []string{"a", ",", "b", ",", ""}},
+	{"abc", ``, 0, []string{"a", "b", "c"}},
+	{"abc", ``, -1, []string{"a", "b", "c", ""}},
+	{"abc", ``, 2, []string{"a", "bc"}},
+	{"abb", `b*`, 0, []string{"a"}},
+	{"foo", `f`, 0, []string{"", "oo"}},
+	{"abc", `(?=c)`, 0, []string{"ab", "c"}},
+	{"héllo", ``, 0, []string{"h", "é", "l", "l", "o"}},
+	{"aéb", `é`, 0, []string{"a", "b"}},
+	{",,", `,`, 0, []string{}},
+	{"", `,`, 0, []string{}},
+	{"", `,`, -1, []string{}},
+	{"", ``, 0, []string{}},
+	{"", `,`, 1, []string{}},
+	{"a,b", `,`, 1, []string{"a,b"}},
+}
+
+func TestRubySplit(t *testing.T) {
+	for _, test := range rubySplitTests {
+		re := MustCompile(test.pattern)
+		if actual := re.RubySplit(test.s, test.limit); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q.RubySplit(%q, %d) = %q; want %q", test.pattern, test.s, test.limit, actual, test.expected)
+		}
+		actual := re.RubySplitBytes([]byte(test.s), test.limit)
+		if len(actual) != len(test.expected) {
+			t.Errorf("%q.RubySplitBytes(%q, %d) = %q; want %q", test.pattern, test.s, test.limit, actual, test.expected)
+			continue
+		}
+		for i := range actual {
+			if string(actual[i]) != test.expected[i] {
+				t.Errorf("%q.RubySplitBytes(%q, %d) = %q; want %q", test.pattern, test.s, test.limit, actual, test.expected)
+				break
+			}
+		}
+	}
+}
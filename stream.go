@@ -0,0 +1,244 @@
+package rubex
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// DefaultMaxMatchLength is the maximum match length of a StreamSearcher
+// created with a maxMatchLength of 0, and of FindReaderIndex,
+// FindReaderSubmatchIndex and MatchReader.
+const DefaultMaxMatchLength = 64 << 10
+
+// the size of a StreamSearcher's first buffer, which grows up to three times
+// the maximum match length
+const streamBufferStartSize = 4 << 10
+
+// the number of empty reads after which a StreamSearcher gives up, as
+// bufio.Reader does
+const maxEmptyReads = 100
+
+// A StreamSearcher finds successive non-overlapping matches in the text read
+// from an io.Reader without holding all of it in memory. It searches a window
+// of at most three times the maximum match length, keeping that much of the
+// text before the search position so anchors and look-behinds see it.
+//
+// The maximum match length bounds the text a match may take up, together
+// with the text its anchors and lookarounds look at on either side. A match
+// is only reported once that much text after its start has been read, or the
+// input has ended. Matches that exceed the bound may be cut short or missed,
+// and a pattern such as \A, or a look-behind reaching further back, may see
+// the start of the window instead of the start of the input. \G, which
+// matches where a search starts, may also match where the window moves on
+// without a match.
+//
+//	s := rubex.NewStreamSearcher(re, file, 0)
+//	for s.Next() {
+//		fmt.Println(s.Index(), string(s.Bytes()))
+//	}
+//	if err := s.Err(); err != nil {
+//		// reading the file failed
+//	}
+type StreamSearcher struct {
+	re  *Regexp
+	r   io.Reader
+	max int
+	// buf holds the input from the absolute offset base on; the next search
+	// starts at buf[pos]
+	buf  []byte
+	base int64
+	pos  int
+	eof  bool
+	done bool
+	err  error
+	// match is the current match, relative to buf
+	match   []int
+	prevEnd int64
+}
+
+// NewStreamSearcher returns a StreamSearcher for the matches of re in the
+// text read from r. maxMatchLength is the longest match, in bytes, it is
+// guaranteed to find; 0 selects DefaultMaxMatchLength.
+func NewStreamSearcher(re *Regexp, r io.Reader, maxMatchLength int) *StreamSearcher {
+	if maxMatchLength <= 0 {
+		maxMatchLength = DefaultMaxMatchLength
+	}
+	return &StreamSearcher{re: re, r: r, max: maxMatchLength, prevEnd: -1}
+}
+
+// Next advances to the next match, which is then available through Index,
+// SubmatchIndex and Bytes. It returns false when there are no more matches,
+// either because the input ended or because reading it failed.
+func (s *StreamSearcher) Next() bool {
+	s.match = nil
+	for !s.done {
+		s.fill()
+		//a match starting at or before limit cannot be changed by the text still to come
+		limit := len(s.buf) - s.max
+		match := s.re.find(s.buf, len(s.buf), s.pos, ONIG_OPTION_DEFAULT)
+		if match == nil || !s.eof && match[0] > limit {
+			if s.eof {
+				s.done = true
+				break
+			}
+			for s.pos < limit {
+				s.pos += s.charLength(s.pos)
+			}
+			continue
+		}
+		s.pos = match[1]
+		if match[0] == match[1] {
+			if s.pos < len(s.buf) {
+				s.pos += s.charLength(s.pos)
+			} else {
+				s.done = true
+			}
+		}
+		end := s.base + int64(match[1])
+		empty := match[0] == match[1] && s.base+int64(match[0]) == s.prevEnd
+		s.prevEnd = end
+		if s.re.goEmptyMatches && empty {
+			continue
+		}
+		s.match = match
+		return true
+	}
+	return false
+}
+
+func (s *StreamSearcher) charLength(offset int) int {
+	if width := s.re.charLength(s.buf, len(s.buf), offset); width > 0 {
+		return width
+	}
+	return 1
+}
+
+// fill drops the text that is no longer needed and reads until twice the
+// maximum match length follows the search position, or the input ends.
+func (s *StreamSearcher) fill() {
+	if drop := s.pos - s.max; drop > 0 {
+		n := copy(s.buf, s.buf[drop:])
+		s.buf = s.buf[:n]
+		s.base += int64(drop)
+		s.pos -= drop
+	}
+	for emptyReads := 0; !s.eof && len(s.buf)-s.pos < 2*s.max; {
+		if len(s.buf) == cap(s.buf) {
+			size := 2 * cap(s.buf)
+			if size < streamBufferStartSize {
+				size = streamBufferStartSize
+			}
+			if size > 3*s.max {
+				size = 3 * s.max
+			}
+			buf := make([]byte, len(s.buf), size)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		if n == 0 && err == nil {
+			if emptyReads++; emptyReads >= maxEmptyReads {
+				err = io.ErrNoProgress
+			}
+		}
+		if err != nil {
+			//the text read so far is searched as if it were all there is
+			if err != io.EOF {
+				s.err = err
+			}
+			s.eof = true
+		}
+	}
+}
+
+// Index returns the absolute offsets of the start and end of the current
+// match in the input.
+func (s *StreamSearcher) Index() []int64 {
+	if s.match == nil {
+		return nil
+	}
+	return s.SubmatchIndex()[:2]
+}
+
+// SubmatchIndex returns the absolute offsets of the current match and of its
+// groups, in pairs as FindSubmatchIndex returns them. Groups that did not
+// take part in the match are -1.
+func (s *StreamSearcher) SubmatchIndex() []int64 {
+	if s.match == nil {
+		return nil
+	}
+	match := make([]int64, len(s.match))
+	for i, offset := range s.match {
+		match[i] = -1
+		if offset >= 0 {
+			match[i] = s.base + int64(offset)
+		}
+	}
+	return match
+}
+
+// Bytes returns the text of the current match. The slice is only valid until
+// the next call to Next.
+func (s *StreamSearcher) Bytes() []byte {
+	if s.match == nil {
+		return nil
+	}
+	return s.buf[s.match[0]:s.match[1]]
+}
+
+// Err returns the first error other than io.EOF that reading the input
+// returned.
+func (s *StreamSearcher) Err() error {
+	return s.err
+}
+
+// findReader returns the first match in the text read from r, with offsets
+// that fit an int, or nil.
+func (re *Regexp) findReader(r io.RuneReader) []int {
+	s := NewStreamSearcher(re, asReader(r), 0)
+	if !s.Next() {
+		return nil
+	}
+	match := make([]int, len(s.match))
+	for i, offset := range s.SubmatchIndex() {
+		match[i] = int(offset)
+	}
+	return match
+}
+
+// asReader returns r itself when it is an io.Reader, and otherwise a reader
+// of the UTF-8 encoding of its runes.
+func asReader(r io.RuneReader) io.Reader {
+	if reader, ok := r.(io.Reader); ok {
+		return reader
+	}
+	return &runeReader{r: r}
+}
+
+type runeReader struct {
+	r       io.RuneReader
+	pending []byte
+	buf     [utf8.UTFMax]byte
+}
+
+func (rr *runeReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(rr.pending) == 0 {
+			c, width, err := rr.r.ReadRune()
+			if err != nil {
+				return n, err
+			}
+			if c == utf8.RuneError && width == 1 {
+				//an invalid byte; keep offsets counting it as one byte
+				rr.pending = append(rr.buf[:0], 0xff)
+			} else {
+				rr.pending = rr.buf[:utf8.EncodeRune(rr.buf[:], c)]
+			}
+		}
+		copied := copy(p[n:], rr.pending)
+		rr.pending = rr.pending[copied:]
+		n += copied
+	}
+	return n, nil
+}
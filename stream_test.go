@@ -0,0 +1,178 @@
+package rubex
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Patterns whose matches, with the text their anchors and lookarounds look
+// at, fit in streamMaxMatchLength bytes of streamText.
+var streamPatterns = []string{
+	`\d+`,
+	`\b\w+\b`,
+	`^\w`,
+	`\w$`,
+	`x*`,
+	`(?<=a)b`,
+	`(é+)(e)?`,
+	`(?<year>\d{4})-(?<month>\d\d)`,
+	`a(?=\n)`,
+}
+
+const streamMaxMatchLength = 8
+
+var streamText = strings.Repeat("ab 1999-12 éée x\nxxa\nabab 42 ", 40)
+
+func streamMatches(re *Regexp, r io.Reader, maxMatchLength int) (matches [][]int64, texts []string, err error) {
+	s := NewStreamSearcher(re, r, maxMatchLength)
+	for s.Next() {
+		matches = append(matches, s.SubmatchIndex())
+		texts = append(texts, string(s.Bytes()))
+		if !reflect.DeepEqual(s.Index(), s.SubmatchIndex()[:2]) {
+			panic("Index differs from SubmatchIndex")
+		}
+	}
+	if s.Next() {
+		panic("Next found a match after returning false")
+	}
+	return matches, texts, s.Err()
+}
+
+func TestStreamSearcher(t *testing.T) {
+	readers := map[string]func(string) io.Reader{
+		"whole":    func(s string) io.Reader { return strings.NewReader(s) },
+		"one byte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+		"half":     func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+	}
+	for _, pattern := range streamPatterns {
+		re := MustCompile(pattern)
+		var expected [][]int64
+		var expectedTexts []string
+		for _, match := range re.FindAllSubmatchIndex([]byte(streamText), -1) {
+			m := make([]int64, len(match))
+			for i, offset := range match {
+				m[i] = int64(offset)
+			}
+			expected = append(expected, m)
+			expectedTexts = append(expectedTexts, streamText[match[0]:match[1]])
+		}
+		for name, reader := range readers {
+			matches, texts, err := streamMatches(re, reader(streamText), streamMaxMatchLength)
+			if err != nil {
+				t.Errorf("%q, %s reader: unexpected error %v", pattern, name, err)
+			}
+			if !reflect.DeepEqual(matches, expected) || !reflect.DeepEqual(texts, expectedTexts) {
+				t.Errorf("%q, %s reader: found %d matches %v; want %d", pattern, name, len(matches), texts, len(expected))
+			}
+		}
+	}
+}
+
+func TestStreamSearcherGoEmptyMatches(t *testing.T) {
+	for _, pattern := range []string{`a*`, `b*|a`, `\b`} {
+		text := strings.Repeat("baaab ", 20)
+		var expected [][]int64
+		for _, match := range regexp.MustCompile(pattern).FindAllStringIndex(text, -1) {
+			expected = append(expected, []int64{int64(match[0]), int64(match[1])})
+		}
+		s := NewStreamSearcher(MustCompileGo(pattern), iotest.OneByteReader(strings.NewReader(text)), 4)
+		var matches [][]int64
+		for s.Next() {
+			matches = append(matches, s.Index())
+		}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("%q: matches %v; want %v", pattern, matches, expected)
+		}
+	}
+}
+
+func TestStreamSearcherLongest(t *testing.T) {
+	re := MustCompile(`a|ab`)
+	re.Longest()
+	_, texts, _ := streamMatches(re, strings.NewReader(strings.Repeat("ab a ", 10)), 4)
+	if len(texts) != 20 || texts[0] != "ab" || texts[1] != "a" {
+		t.Errorf("found %q", texts)
+	}
+}
+
+// a reader of size bytes of 'x' followed by "needle"
+type haystack struct {
+	size int64
+	read int64
+}
+
+func (h *haystack) Read(p []byte) (int, error) {
+	if h.read >= h.size+6 {
+		return 0, io.EOF
+	}
+	n := 0
+	for ; n < len(p) && h.read < h.size+6; n++ {
+		if h.read < h.size {
+			p[n] = 'x'
+		} else {
+			p[n] = "needle"[h.read-h.size]
+		}
+		h.read++
+	}
+	return n, nil
+}
+
+func TestStreamSearcherBoundedMemory(t *testing.T) {
+	const size = 8 << 20
+	s := NewStreamSearcher(MustCompile(`ne+dle`), &haystack{size: size}, 1024)
+	if !s.Next() {
+		t.Fatal("no match")
+	}
+	if expected := []int64{size, size + 6}; !reflect.DeepEqual(s.Index(), expected) {
+		t.Errorf("Index() = %v; want %v", s.Index(), expected)
+	}
+	if cap(s.buf) > 3*1024 {
+		t.Errorf("buffered %d bytes", cap(s.buf))
+	}
+	if s.Next() {
+		t.Errorf("found a second match")
+	}
+}
+
+func TestStreamSearcherReadError(t *testing.T) {
+	failure := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("a1 b22 c"), iotest.ErrReader(failure))
+	_, texts, err := streamMatches(MustCompile(`\d+`), r, 0)
+	if err != failure || !reflect.DeepEqual(texts, []string{"1", "22"}) {
+		t.Errorf("found %q, %v", texts, err)
+	}
+	_, texts, err = streamMatches(MustCompile(`x`), strings.NewReader(""), 0)
+	if err != nil || texts != nil {
+		t.Errorf("empty input: found %q, %v", texts, err)
+	}
+}
+
+// runeOnly hides every method of its reader but ReadRune.
+type runeOnly struct {
+	r io.RuneReader
+}
+
+func (r runeOnly) ReadRune() (rune, int, error) {
+	return r.r.ReadRune()
+}
+
+func TestFindReaderRunes(t *testing.T) {
+	re := MustCompile(`(é)(b)`)
+	text := "a\xffé\xfeéb"
+	expected := []int{5, 8, 5, 7, 7, 8}
+	if actual := re.FindReaderSubmatchIndex(runeOnly{strings.NewReader(text)}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FindReaderSubmatchIndex = %v; want %v", actual, expected)
+	}
+	if actual := re.FindReaderIndex(runeOnly{bytes.NewReader([]byte(text))}); !reflect.DeepEqual(actual, expected[:2]) {
+		t.Errorf("FindReaderIndex = %v; want %v", actual, expected[:2])
+	}
+	if re.MatchReader(runeOnly{strings.NewReader("éc")}) {
+		t.Errorf("MatchReader matched")
+	}
+}
@@ -0,0 +1,58 @@
+package rubex
+
+// setGroupNames records the groups each name was given to. A name used for
+// several groups refers to the last of them in \k<name> and in replacement
+// templates, as it does in Ruby.
+func (re *Regexp) setGroupNames(groupNames map[string][]int) {
+	re.subexpNames = make([]string, re.numCaptures)
+	if len(groupNames) == 0 {
+		return
+	}
+	re.namedGroupInfo = make(NamedGroupInfo)
+	for name, groups := range groupNames {
+		re.namedGroupInfo[name] = groups[len(groups)-1]
+		for _, group := range groups {
+			if group > 0 && group < len(re.subexpNames) {
+				re.subexpNames[group] = name
+			}
+		}
+	}
+}
+
+// SubexpNames returns the names of the parenthesized subexpressions in this
+// Regexp. The name for the first sub-expression is names[1], so that if m is a
+// match slice, the name for m[i] is SubexpNames()[i]. Since the Regexp as a
+// whole cannot be named, names[0] is always the empty string. Unnamed groups
+// are not captured when the pattern has named ones, unless it was compiled
+// with ONIG_OPTION_CAPTURE_GROUP. The slice should not be modified.
+func (re *Regexp) SubexpNames() []string {
+	return re.subexpNames
+}
+
+// SubexpIndex returns the index of the subexpression with the given name, or
+// -1 if there is no subexpression with that name. When several groups share
+// the name, it returns the last, the one \k<name> refers to; SubexpIndices
+// returns them all.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name != "" {
+		if index, ok := re.namedGroupInfo[name]; ok {
+			return index
+		}
+	}
+	return -1
+}
+
+// SubexpIndices returns the indexes of every subexpression with the given
+// name, in increasing order, or nil if there is none.
+func (re *Regexp) SubexpIndices(name string) []int {
+	if name == "" {
+		return nil
+	}
+	var indices []int
+	for i, subexpName := range re.subexpNames {
+		if subexpName == name {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
@@ -0,0 +1,69 @@
+package rubex
+
+import (
+	"reflect"
+	"testing"
+)
+
+var subexpTests = []struct {
+	pattern string
+	option  Option
+	names   []string
+	indices map[string][]int
+}{
+	{``, ONIG_OPTION_NONE, []string{""}, nil},
+	{`(a)(b)`, ONIG_OPTION_NONE, []string{"", "", ""}, nil},
+	{`(?<first>a)(?<last>b)`, ONIG_OPTION_NONE, []string{"", "first", "last"}, map[string][]int{"first": {1}, "last": {2}}},
+	// unnamed groups are not captured next to named ones...
+	{`(?<first>a)(b)(?<last>c)`, ONIG_OPTION_NONE, []string{"", "first", "last"}, map[string][]int{"first": {1}, "last": {2}}},
+	// ...unless asked for
+	{`(?<first>a)(b)(?<last>c)`, ONIG_OPTION_CAPTURE_GROUP, []string{"", "first", "", "last"}, map[string][]int{"first": {1}, "last": {3}}},
+	{`(?<x>a)|(?<y>b)(?<x>c)`, ONIG_OPTION_NONE, []string{"", "x", "y", "x"}, map[string][]int{"x": {1, 3}, "y": {2}}},
+	{`(?<日本>a)`, ONIG_OPTION_NONE, []string{"", "日本"}, map[string][]int{"日本": {1}}},
+}
+
+func TestSubexpNames(t *testing.T) {
+	for _, test := range subexpTests {
+		re := MustCompileWithOption(test.pattern, test.option)
+		if names := re.SubexpNames(); !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%q.SubexpNames() = %q; want %q", test.pattern, names, test.names)
+		}
+		if len(re.SubexpNames()) != re.NumSubexp()+1 {
+			t.Errorf("%q: %d names for %d subexpressions", test.pattern, len(re.SubexpNames()), re.NumSubexp())
+		}
+		for name, indices := range test.indices {
+			if actual := re.SubexpIndices(name); !reflect.DeepEqual(actual, indices) {
+				t.Errorf("%q.SubexpIndices(%q) = %v; want %v", test.pattern, name, actual, indices)
+			}
+			if index := re.SubexpIndex(name); index != indices[len(indices)-1] {
+				t.Errorf("%q.SubexpIndex(%q) = %d; want %d", test.pattern, name, index, indices[len(indices)-1])
+			}
+		}
+		for _, name := range []string{"", "missing"} {
+			if index := re.SubexpIndex(name); index != -1 {
+				t.Errorf("%q.SubexpIndex(%q) = %d; want -1", test.pattern, name, index)
+			}
+			if indices := re.SubexpIndices(name); indices != nil {
+				t.Errorf("%q.SubexpIndices(%q) = %v; want nil", test.pattern, name, indices)
+			}
+		}
+	}
+}
+
+func TestSubexpIndexMatches(t *testing.T) {
+	re := MustCompile(`(?<year>\d{4})-(?<month>\d{2})`)
+	match := re.FindStringSubmatch("on 2012-04")
+	if month := match[re.SubexpIndex("month")]; month != "04" {
+		t.Errorf("month = %q; want %q", month, "04")
+	}
+	names := re.SubexpNames()
+	fields := map[string]string{}
+	for i, value := range match {
+		if names[i] != "" {
+			fields[names[i]] = value
+		}
+	}
+	if expected := map[string]string{"year": "2012", "month": "04"}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("fields = %q; want %q", fields, expected)
+	}
+}
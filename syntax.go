@@ -0,0 +1,53 @@
+package rubex
+
+import (
+	"strconv"
+)
+
+// Syntax selects the regular expression dialect a pattern is written in.
+type Syntax int
+
+// The predefined Oniguruma syntaxes. The order matches the syntax table in
+// chelper.c.
+const (
+	ONIG_SYNTAX_RUBY Syntax = iota
+	ONIG_SYNTAX_PERL
+	ONIG_SYNTAX_PERL_NT
+	ONIG_SYNTAX_JAVA
+	ONIG_SYNTAX_POSIX_BASIC
+	ONIG_SYNTAX_POSIX_EXTENDED
+	ONIG_SYNTAX_EMACS
+	ONIG_SYNTAX_GREP
+	ONIG_SYNTAX_GNU_REGEX
+	ONIG_SYNTAX_ASIS
+
+	ONIG_SYNTAX_DEFAULT = ONIG_SYNTAX_RUBY
+)
+
+var syntaxNames = []string{
+	ONIG_SYNTAX_RUBY:           "ONIG_SYNTAX_RUBY",
+	ONIG_SYNTAX_PERL:           "ONIG_SYNTAX_PERL",
+	ONIG_SYNTAX_PERL_NT:        "ONIG_SYNTAX_PERL_NT",
+	ONIG_SYNTAX_JAVA:           "ONIG_SYNTAX_JAVA",
+	ONIG_SYNTAX_POSIX_BASIC:    "ONIG_SYNTAX_POSIX_BASIC",
+	ONIG_SYNTAX_POSIX_EXTENDED: "ONIG_SYNTAX_POSIX_EXTENDED",
+	ONIG_SYNTAX_EMACS:          "ONIG_SYNTAX_EMACS",
+	ONIG_SYNTAX_GREP:           "ONIG_SYNTAX_GREP",
+	ONIG_SYNTAX_GNU_REGEX:      "ONIG_SYNTAX_GNU_REGEX",
+	ONIG_SYNTAX_ASIS:           "ONIG_SYNTAX_ASIS",
+}
+
+func (syntax Syntax) String() string {
+	if syntax >= 0 && int(syntax) < len(syntaxNames) {
+		return syntaxNames[syntax]
+	}
+	if _, name := lookupCustomSyntax(syntax); name != "" {
+		return name
+	}
+	return "Syntax(" + strconv.Itoa(int(syntax)) + ")"
+}
+
+// Syntax returns the syntax the pattern was compiled with.
+func (re *Regexp) Syntax() Syntax {
+	return re.syntax
+}
@@ -0,0 +1,19 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+/*
+#include <oniguruma.h>
+#include "chelper.h"
+*/
+import "C"
+
+// onigSyntax returns the Oniguruma syntax table for syntax, or nil if there
+// is none.
+func (syntax Syntax) onigSyntax() *C.OnigSyntaxType {
+	if syntax >= firstCustomSyntax {
+		table, _ := lookupCustomSyntax(syntax)
+		return table
+	}
+	return C.GetOnigSyntax(C.int(syntax))
+}
@@ -0,0 +1,64 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"testing"
+)
+
+type syntaxTest struct {
+	syntax  Syntax
+	pattern string
+	input   string
+	match   string
+}
+
+var syntaxTests = []syntaxTest{
+	{ONIG_SYNTAX_RUBY, `(?<x>a)\k<x>`, "baab", "aa"},
+	{ONIG_SYNTAX_RUBY, `a{2}`, "aaa", "aa"},
+	{ONIG_SYNTAX_PERL, `\Qa.b\E`, "axb a.b", "a.b"},
+	{ONIG_SYNTAX_PERL, `(?s)a.b`, "a\nb", "a\nb"},
+	{ONIG_SYNTAX_PERL_NT, `(?<x>a)\k<x>`, "baab", "aa"},
+	{ONIG_SYNTAX_JAVA, `\Qa+\E`, "aa a+", "a+"},
+	{ONIG_SYNTAX_POSIX_BASIC, `a\{2\}`, "aaa", "aa"},
+	{ONIG_SYNTAX_POSIX_BASIC, `a{2}`, "aa a{2}", "a{2}"},
+	{ONIG_SYNTAX_POSIX_BASIC, `\(ab\)*c`, "ababc", "ababc"},
+	{ONIG_SYNTAX_POSIX_EXTENDED, `(ab)+c`, "ababc", "ababc"},
+	{ONIG_SYNTAX_POSIX_EXTENDED, `[[:digit:]]+`, "ab123", "123"},
+	{ONIG_SYNTAX_EMACS, `\(foo\|bar\)+`, "xbarfoo", "barfoo"},
+	{ONIG_SYNTAX_GREP, `a\+`, "caa", "aa"},
+	{ONIG_SYNTAX_GREP, `a+`, "aa a+", "a+"},
+	{ONIG_SYNTAX_GNU_REGEX, `\w+`, "  abc ", "abc"},
+	{ONIG_SYNTAX_ASIS, `a.b*`, "axbb a.b*", "a.b*"},
+}
+
+func TestCompileWithSyntax(t *testing.T) {
+	for _, tc := range syntaxTests {
+		re, err := CompileWithSyntax(tc.pattern, ONIG_OPTION_DEFAULT, tc.syntax)
+		if err != nil {
+			t.Errorf("%s: unexpected error compiling %q: %v", tc.syntax, tc.pattern, err)
+			continue
+		}
+		if re.Syntax() != tc.syntax {
+			t.Errorf("Syntax() = %s; want %s", re.Syntax(), tc.syntax)
+		}
+		if actual := re.FindString(tc.input); actual != tc.match {
+			t.Errorf("%s: %q.FindString(%q) = %q; want %q", tc.syntax, tc.pattern, tc.input, actual, tc.match)
+		}
+	}
+}
+
+func TestCompileWithUnknownSyntax(t *testing.T) {
+	if _, err := CompileWithSyntax("a", ONIG_OPTION_DEFAULT, Syntax(-1)); err == nil {
+		t.Errorf("expected an error for an unknown syntax")
+	}
+	if s := Syntax(100).String(); s != "Syntax(100)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestDefaultSyntaxIsRuby(t *testing.T) {
+	if re := MustCompile("a"); re.Syntax() != ONIG_SYNTAX_RUBY {
+		t.Errorf("Syntax() = %s; want ONIG_SYNTAX_RUBY", re.Syntax())
+	}
+}
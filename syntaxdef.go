@@ -0,0 +1,213 @@
+package rubex
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// Custom syntaxes are numbered from firstCustomSyntax so they never collide
+// with the predefined ones.
+const firstCustomSyntax Syntax = 1 << 16
+
+const numMetaChars = ONIG_META_CHAR_ANYCHAR_ANYTIME + 1
+
+// reservedSyntaxOp2 is the OP2 bit between
+// ONIG_SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT and ONIG_SYN_OP2_ESC_H_XDIGIT, which
+// Oniguruma 6.9 leaves unused. It is not a known operator, so definitions
+// that set it fail validation; check it again when moving to a newer
+// Oniguruma.
+const reservedSyntaxOp2 = 1 << 18
+
+// the bits Oniguruma 6.9 defines
+const (
+	knownSyntaxOps       = (ONIG_SYN_OP_ESC_O_BRACE_OCTAL << 1) - 1
+	knownSyntaxOps2      = ((ONIG_SYN_OP2_QMARK_CAPITAL_P_NAME << 1) - 1) &^ reservedSyntaxOp2
+	knownSyntaxBehaviors = ((ONIG_SYN_WHOLE_OPTIONS << 1) - 1) |
+		ONIG_SYN_NOT_NEWLINE_IN_NEGATIVE_CC | ONIG_SYN_BACKSLASH_ESCAPE_IN_CC |
+		ONIG_SYN_ALLOW_EMPTY_RANGE_IN_CC | ONIG_SYN_ALLOW_DOUBLE_RANGE_OP_IN_CC |
+		ONIG_SYN_WARN_CC_OP_NOT_ESCAPED | ONIG_SYN_WARN_REDUNDANT_NESTED_REPEAT |
+		ONIG_SYN_ALLOW_INVALID_CODE_END_OF_RANGE_IN_CC | ONIG_SYN_CONTEXT_INDEP_ANCHORS
+)
+
+// registered syntax tables live as long as the process, like Oniguruma's own
+// predefined tables.
+var customSyntaxes struct {
+	sync.RWMutex
+	tables []syntaxTable
+	names  []string
+}
+
+// A SyntaxDef builds a custom syntax from one of the predefined (or already
+// registered) ones by switching operators and behaviors on or off, changing the
+// default options, and remapping meta characters. The setters return the
+// SyntaxDef so they can be chained; Register validates the result and returns
+// a Syntax for NewRegexpWithSyntax and CompileWithSyntax.
+//
+// For example, Ruby syntax without backreferences:
+//
+//	syntax, err := NewSyntaxDef(ONIG_SYNTAX_RUBY).
+//		DisableOp(ONIG_SYN_OP_DECIMAL_BACKREF).
+//		DisableOp2(ONIG_SYN_OP2_ESC_K_NAMED_BACKREF).
+//		Register("ruby-without-backrefs")
+type SyntaxDef struct {
+	base      *SyntaxDef
+	op        int
+	op2       int
+	behavior  int
+	options   int
+	metaChars [numMetaChars]rune
+	err       error
+}
+
+// NewSyntaxDef starts a syntax definition from a copy of base.
+func NewSyntaxDef(base Syntax) *SyntaxDef {
+	def, err := baseSyntaxDef(base)
+	if err != nil {
+		return &SyntaxDef{err: err}
+	}
+	//newer Oniguruma releases define more bits than rubex knows about; the
+	//ones the base syntax already uses are accepted as they are
+	copied := *def
+	def.base = &copied
+	return def
+}
+
+// EnableOp turns on the given ONIG_SYN_OP_* operators.
+func (def *SyntaxDef) EnableOp(op int) *SyntaxDef {
+	def.op |= op
+	return def
+}
+
+// DisableOp turns off the given ONIG_SYN_OP_* operators.
+func (def *SyntaxDef) DisableOp(op int) *SyntaxDef {
+	def.op &^= op
+	return def
+}
+
+// EnableOp2 turns on the given ONIG_SYN_OP2_* operators.
+func (def *SyntaxDef) EnableOp2(op2 int) *SyntaxDef {
+	def.op2 |= op2
+	return def
+}
+
+// DisableOp2 turns off the given ONIG_SYN_OP2_* operators.
+func (def *SyntaxDef) DisableOp2(op2 int) *SyntaxDef {
+	def.op2 &^= op2
+	return def
+}
+
+// EnableBehavior turns on the given ONIG_SYN_* behaviors.
+func (def *SyntaxDef) EnableBehavior(behavior int) *SyntaxDef {
+	def.behavior |= behavior
+	return def
+}
+
+// DisableBehavior turns off the given ONIG_SYN_* behaviors.
+func (def *SyntaxDef) DisableBehavior(behavior int) *SyntaxDef {
+	def.behavior &^= behavior
+	return def
+}
+
+// SetOptions sets the ONIG_OPTION_* options every pattern of this syntax is
+// compiled with, in addition to the options passed at compile time.
+func (def *SyntaxDef) SetOptions(option Option) *SyntaxDef {
+	def.options = int(option)
+	return def
+}
+
+// SetMetaChar maps one of the ONIG_META_CHAR_* roles to the character c, or
+// disables it when c is ONIG_INEFFECTIVE_META_CHAR. Meta characters only take
+// effect with ONIG_SYN_OP_VARIABLE_META_CHARACTERS, which this turns on.
+func (def *SyntaxDef) SetMetaChar(what int, c rune) *SyntaxDef {
+	if what < 0 || what >= numMetaChars {
+		if def.err == nil {
+			def.err = fmt.Errorf("rubex: unknown meta character specifier %d", what)
+		}
+		return def
+	}
+	def.metaChars[what] = c
+	def.op |= ONIG_SYN_OP_VARIABLE_META_CHARACTERS
+	return def
+}
+
+// unknownBits returns the bits of value that are neither known nor set in
+// base. The bits are those of a C unsigned int held in an int; going through
+// uint keeps bit 31 from spreading into the bits above it on 32-bit platforms.
+func unknownBits(value int, known uint64, base int) uint64 {
+	return uint64(uint(value)) &^ (known | uint64(uint(base)))
+}
+
+func (def *SyntaxDef) validate() error {
+	if def.err != nil {
+		return def.err
+	}
+	base := def.base
+	if unknown := unknownBits(def.op, knownSyntaxOps, base.op); unknown != 0 {
+		return fmt.Errorf("rubex: unknown syntax operators %#x", unknown)
+	}
+	if unknown := unknownBits(def.op2, knownSyntaxOps2, base.op2); unknown != 0 {
+		return fmt.Errorf("rubex: unknown syntax operators (op2) %#x", unknown)
+	}
+	if unknown := unknownBits(def.behavior, knownSyntaxBehaviors, base.behavior); unknown != 0 {
+		return fmt.Errorf("rubex: unknown syntax behaviors %#x", unknown)
+	}
+	if invalid := def.options &^ (compileTimeOptions | base.options); invalid != 0 {
+		return fmt.Errorf("rubex: options %#x are not compile-time options", invalid)
+	}
+	if def.op&ONIG_SYN_OP_VARIABLE_META_CHARACTERS == 0 {
+		if def.metaChars != base.metaChars {
+			return errors.New("rubex: meta characters need ONIG_SYN_OP_VARIABLE_META_CHARACTERS")
+		}
+		return nil
+	}
+	seen := make(map[rune]bool)
+	for _, c := range def.metaChars {
+		if c == ONIG_INEFFECTIVE_META_CHAR {
+			continue
+		}
+		if c > unicode.MaxASCII || !unicode.IsPunct(c) && !unicode.IsSymbol(c) {
+			return fmt.Errorf("rubex: meta character %q must be ASCII punctuation", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("rubex: meta character %q is mapped more than once", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// Register validates the definition and makes it available under name, which
+// must be unique. The returned Syntax stays valid for the life of the process.
+func (def *SyntaxDef) Register(name string) (Syntax, error) {
+	if err := def.validate(); err != nil {
+		return 0, err
+	}
+	if name == "" {
+		return 0, errors.New("rubex: a custom syntax needs a name")
+	}
+
+	customSyntaxes.Lock()
+	defer customSyntaxes.Unlock()
+	for _, names := range [][]string{syntaxNames, customSyntaxes.names} {
+		for _, registered := range names {
+			if registered == name {
+				return 0, fmt.Errorf("rubex: syntax %q is already registered", name)
+			}
+		}
+	}
+	customSyntaxes.tables = append(customSyntaxes.tables, newSyntaxTable(def))
+	customSyntaxes.names = append(customSyntaxes.names, name)
+	return firstCustomSyntax + Syntax(len(customSyntaxes.tables)-1), nil
+}
+
+func lookupCustomSyntax(syntax Syntax) (syntaxTable, string) {
+	customSyntaxes.RLock()
+	defer customSyntaxes.RUnlock()
+	i := int(syntax - firstCustomSyntax)
+	if i < 0 || i >= len(customSyntaxes.tables) {
+		return nil, ""
+	}
+	return customSyntaxes.tables[i], customSyntaxes.names[i]
+}
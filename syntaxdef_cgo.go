@@ -0,0 +1,52 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+/*
+#include <stdlib.h>
+#include <oniguruma.h>
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+type syntaxTable = *C.OnigSyntaxType
+
+func newSyntaxTable(def *SyntaxDef) syntaxTable {
+	table := (*C.OnigSyntaxType)(C.malloc(C.size_t(unsafe.Sizeof(C.OnigSyntaxType{}))))
+	C.onig_copy_syntax(table, C.GetOnigSyntax(C.int(ONIG_SYNTAX_DEFAULT)))
+	C.onig_set_syntax_op(table, C.uint(def.op))
+	C.onig_set_syntax_op2(table, C.uint(def.op2))
+	C.onig_set_syntax_behavior(table, C.uint(def.behavior))
+	C.onig_set_syntax_options(table, C.OnigOptionType(def.options))
+	for what, c := range def.metaChars {
+		C.onig_set_meta_char(table, C.uint(what), C.OnigCodePoint(c))
+	}
+	return table
+}
+
+// baseSyntaxDef reads the definition of a predefined or registered syntax.
+func baseSyntaxDef(base Syntax) (*SyntaxDef, error) {
+	def := &SyntaxDef{}
+	table := base.onigSyntax()
+	if table == nil {
+		return nil, fmt.Errorf("rubex: unknown base syntax %s", base)
+	}
+	def.op = int(table.op)
+	def.op2 = int(table.op2)
+	def.behavior = int(table.behavior)
+	def.options = int(table.options)
+	def.metaChars = [numMetaChars]rune{
+		ONIG_META_CHAR_ESCAPE:           rune(table.meta_char_table.esc),
+		ONIG_META_CHAR_ANYCHAR:          rune(table.meta_char_table.anychar),
+		ONIG_META_CHAR_ANYTIME:          rune(table.meta_char_table.anytime),
+		ONIG_META_CHAR_ZERO_OR_ONE_TIME: rune(table.meta_char_table.zero_or_one_time),
+		ONIG_META_CHAR_ONE_OR_MORE_TIME: rune(table.meta_char_table.one_or_more_time),
+		ONIG_META_CHAR_ANYCHAR_ANYTIME:  rune(table.meta_char_table.anychar_anytime),
+	}
+	return def, nil
+}
@@ -0,0 +1,24 @@
+//go:build nocgo || !cgo
+
+package rubex
+
+import (
+	"fmt"
+)
+
+// Without cgo a registered syntax is only a name; compiling with it fails.
+type syntaxTable = *SyntaxDef
+
+func newSyntaxTable(def *SyntaxDef) syntaxTable {
+	return def
+}
+
+// baseSyntaxDef returns the definition of a registered syntax. The predefined
+// syntaxes live in Oniguruma and cannot be read without it.
+func baseSyntaxDef(base Syntax) (*SyntaxDef, error) {
+	if table, _ := lookupCustomSyntax(base); table != nil {
+		def := *table
+		return &def, nil
+	}
+	return nil, fmt.Errorf("rubex: base syntax %s is not available without cgo", base)
+}
@@ -0,0 +1,120 @@
+//go:build cgo && !nocgo
+
+package rubex
+
+import (
+	"fmt"
+	"testing"
+)
+
+func mustRegister(def *SyntaxDef, name string) Syntax {
+	syntax, err := def.Register(name)
+	if err != nil {
+		panic(err)
+	}
+	return syntax
+}
+
+var (
+	rubyWithoutBackrefs = mustRegister(NewSyntaxDef(ONIG_SYNTAX_RUBY).
+				DisableOp(ONIG_SYN_OP_DECIMAL_BACKREF).
+				DisableOp2(ONIG_SYN_OP2_ESC_K_NAMED_BACKREF), "test-ruby-without-backrefs")
+	rubyWithoutAlternation = mustRegister(NewSyntaxDef(ONIG_SYNTAX_RUBY).
+				DisableOp(ONIG_SYN_OP_VBAR_ALT), "test-ruby-without-alternation")
+	percentEscape = mustRegister(NewSyntaxDef(ONIG_SYNTAX_RUBY).
+			SetMetaChar(ONIG_META_CHAR_ESCAPE, '%'), "test-percent-escape")
+	underscoreAnyChar = mustRegister(NewSyntaxDef(ONIG_SYNTAX_RUBY).
+				DisableOp(ONIG_SYN_OP_DOT_ANYCHAR).
+				SetMetaChar(ONIG_META_CHAR_ANYCHAR, '_'), "test-underscore-anychar")
+	caseless = mustRegister(NewSyntaxDef(ONIG_SYNTAX_RUBY).
+			SetOptions(ONIG_OPTION_IGNORECASE), "test-caseless")
+	javaWithKeep = mustRegister(NewSyntaxDef(ONIG_SYNTAX_JAVA).
+			EnableOp2(ONIG_SYN_OP2_ESC_CAPITAL_K_KEEP|ONIG_SYN_OP2_ESC_CAPITAL_R_GENERAL_NEWLINE), "test-java-with-keep")
+)
+
+func TestCustomSyntaxString(t *testing.T) {
+	if s := rubyWithoutBackrefs.String(); s != "test-ruby-without-backrefs" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+var syntaxDefTests = []struct {
+	syntax  *Syntax
+	pattern string
+	input   string
+	match   string
+}{
+	// with backreferences disabled \1 is an octal escape
+	{&rubyWithoutBackrefs, `(a)\1`, "aa a\x01", "a\x01"},
+	{&rubyWithoutBackrefs, `(a)b`, "cab", "ab"},
+	{&rubyWithoutAlternation, `a|b`, "b a|b", "a|b"},
+	{&percentEscape, `%d+`, "ab12", "12"},
+	{&percentEscape, `\d`, `4\d`, `\d`},
+	{&underscoreAnyChar, `a_c`, "abc", "abc"},
+	{&underscoreAnyChar, `a.c`, "abc a.c", "a.c"},
+	{&caseless, `abc`, "xABC", "ABC"},
+	{&javaWithKeep, `a\Kb`, "ab", "b"},
+	{&javaWithKeep, `a\R`, "ba\r\n", "a\r\n"},
+}
+
+func TestSyntaxDefCompile(t *testing.T) {
+	for _, tc := range syntaxDefTests {
+		re, err := NewRegexpWithSyntax(tc.pattern, ONIG_OPTION_DEFAULT, *tc.syntax)
+		if err != nil {
+			t.Errorf("%s: unexpected error compiling %q: %v", *tc.syntax, tc.pattern, err)
+			continue
+		}
+		if actual := re.FindString(tc.input); actual != tc.match {
+			t.Errorf("%s: %q.FindString(%q) = %q; want %q", *tc.syntax, tc.pattern, tc.input, actual, tc.match)
+		}
+	}
+	// the same patterns behave differently under plain Ruby syntax
+	if re := MustCompile(`(a)\1`); re.FindString("aa a\x01") != "aa" {
+		t.Errorf("ruby syntax was modified by a custom syntax")
+	}
+	// and \k is no longer a named backreference
+	if re := MustCompileWithSyntax(`(?<x>a)\k<x>`, ONIG_OPTION_DEFAULT, rubyWithoutBackrefs); re.FindString("aa ak<x>") != "ak<x>" {
+		t.Errorf("\\k<x> still refers to a named group")
+	}
+}
+
+func TestSyntaxDefValidation(t *testing.T) {
+	invalid := map[string]*SyntaxDef{
+		"unknown base":           NewSyntaxDef(Syntax(-1)),
+		"unknown op2":            NewSyntaxDef(ONIG_SYNTAX_RUBY).EnableOp2(reservedSyntaxOp2),
+		"unknown behavior":       NewSyntaxDef(ONIG_SYNTAX_RUBY).EnableBehavior(1 << 15),
+		"search-time option":     NewSyntaxDef(ONIG_SYNTAX_RUBY).SetOptions(ONIG_OPTION_NOTBOL),
+		"unknown meta char":      NewSyntaxDef(ONIG_SYNTAX_RUBY).SetMetaChar(42, '%'),
+		"letter as meta char":    NewSyntaxDef(ONIG_SYNTAX_RUBY).SetMetaChar(ONIG_META_CHAR_ESCAPE, 'e'),
+		"duplicate meta char":    NewSyntaxDef(ONIG_SYNTAX_RUBY).SetMetaChar(ONIG_META_CHAR_ANYCHAR, '\\'),
+		"meta chars not enabled": NewSyntaxDef(ONIG_SYNTAX_RUBY).SetMetaChar(ONIG_META_CHAR_ANYCHAR, '_').DisableOp(ONIG_SYN_OP_VARIABLE_META_CHARACTERS),
+	}
+	for name, def := range invalid {
+		if _, err := def.Register("test-invalid-" + name); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+	// every 6.9 bit is known, even to a base syntax that has none of them
+	known := []*SyntaxDef{
+		NewSyntaxDef(ONIG_SYNTAX_ASIS).EnableOp(ONIG_SYN_OP_ESC_O_BRACE_OCTAL),
+		NewSyntaxDef(ONIG_SYNTAX_ASIS).EnableOp2(ONIG_SYN_OP2_QMARK_LPAREN_IF_ELSE | ONIG_SYN_OP2_ESC_CAPITAL_K_KEEP |
+			ONIG_SYN_OP2_ESC_CAPITAL_R_GENERAL_NEWLINE | ONIG_SYN_OP2_ESC_CAPITAL_N_O_SUPER_DOT |
+			ONIG_SYN_OP2_QMARK_TILDE_ABSENT_GROUP | ONIG_SYN_OP2_ESC_X_Y_TEXT_SEGMENT |
+			ONIG_SYN_OP2_QMARK_PERL_SUBEXP_CALL | ONIG_SYN_OP2_QMARK_BRACE_CALLOUT_CONTENTS |
+			ONIG_SYN_OP2_ASTERISK_CALLOUT_NAME | ONIG_SYN_OP2_OPTION_ONIGURUMA | ONIG_SYN_OP2_QMARK_CAPITAL_P_NAME),
+		NewSyntaxDef(ONIG_SYNTAX_ASIS).EnableBehavior(ONIG_SYN_ISOLATED_OPTION_CONTINUE_BRANCH |
+			ONIG_SYN_VARIABLE_LEN_LOOK_BEHIND | ONIG_SYN_PYTHON | ONIG_SYN_WHOLE_OPTIONS |
+			ONIG_SYN_ALLOW_INVALID_CODE_END_OF_RANGE_IN_CC | ONIG_SYN_CONTEXT_INDEP_ANCHORS),
+	}
+	for i, def := range known {
+		if _, err := def.Register(fmt.Sprintf("test-known-%d", i)); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+	}
+	if _, err := NewSyntaxDef(ONIG_SYNTAX_RUBY).Register("ONIG_SYNTAX_PERL"); err == nil {
+		t.Errorf("expected an error for a name already in use")
+	}
+	if _, err := NewSyntaxDef(ONIG_SYNTAX_RUBY).Register(""); err == nil {
+		t.Errorf("expected an error for an empty name")
+	}
+}